@@ -0,0 +1,316 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// acknowledgeBlockID - the block with the button of a request to acknowledge a role
+	acknowledgeBlockID = "incident_acknowledge"
+
+	actionAcknowledge = "acknowledge_role"
+)
+
+// defaultAcknowledgementWindow - how long somebody has to acknowledge a role if not configured
+const defaultAcknowledgementWindow = 15 * time.Minute
+
+// defaultAcknowledgementKey - the key of the windows by severity that applies to the severities missing from them
+const defaultAcknowledgementKey = "default"
+
+// ParseAcknowledgementWindows - parse how long somebody has to acknowledge a role before the next backup is asked,
+// by severity with durations like "5m", the severities are lowercased and "default" applies to the severities
+// missing from them
+func ParseAcknowledgementWindows(windows map[string]string) (map[string]time.Duration, error) {
+	return parseSeverityDurations("acknowledgement window", windows)
+}
+
+// acknowledgementWindow - how long somebody has to acknowledge a role of an incident of the given severity
+func (h *botHandler) acknowledgementWindow(severity string) time.Duration {
+	return severityDuration(h.opts.AcknowledgementWindows, defaultAcknowledgementKey, severity, defaultAcknowledgementWindow)
+}
+
+// acknowledgeValue - the value of the acknowledge button for a role of an incident
+func acknowledgeValue(channelID, role string) string {
+	return channelID + " " + role
+}
+
+// parseAcknowledgeValue - the incident channel and the role of the value of an acknowledge button
+func parseAcknowledgeValue(value string) (channelID, role string, ok bool) {
+	return strings.Cut(value, " ")
+}
+
+// acknowledgeBlocks - a request to acknowledge a role with a button to do so
+func (h *botHandler) acknowledgeBlocks(text, channelID, role string) []slack.Block {
+	button := slack.NewButtonBlockElement(actionAcknowledge, acknowledgeValue(channelID, role),
+		slack.NewTextBlockObject(slack.PlainTextType, h.localizer().MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Acknowledge",
+				Other: "Acknowledge"},
+		}), false, false))
+	button.Style = slack.StylePrimary
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock(acknowledgeBlockID, button),
+	}
+}
+
+// requestAcknowledgements - ask the holders of the roles of the incident to acknowledge them with a direct message
+func (h *botHandler) requestAcknowledgements(ctx context.Context, inc *store.Incident, roles ...string) {
+	log := zerolog.Ctx(ctx)
+	now := time.Now()
+	for _, role := range roles {
+		assignee := roleHolder(inc, role)
+		if assignee == "" {
+			continue
+		}
+		text := h.localizer().MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID: "AcknowledgeRole",
+				Other: ":wave: You are the {{.Role}} of {{.Incident}}\n*Summary:* {{.Summary}}\n*Severity:* {{.Severity}}\n" +
+					"Acknowledge that you are on it, otherwise the next backup is asked in {{.Window}}"},
+			TemplateData: map[string]string{
+				"Role":     role,
+				"Incident": incidentLabel(inc.Number, inc.ChannelID),
+				"Summary":  inc.Summary,
+				"Severity": inc.Severity,
+				"Window":   formatAge(h.acknowledgementWindow(inc.Severity)),
+			},
+		})
+		if err := h.sendMessage(ctx, assignee, slack.MsgOptionText(text, false),
+			slack.MsgOptionBlocks(h.acknowledgeBlocks(text, inc.ChannelID, role)...)); err != nil {
+			log.Error().Err(err).Str("role", role).Msg("Could not ask for acknowledgement")
+		}
+		// The window starts even if the message failed, so that a backup is asked
+		if _, err := h.incidents.Update(ctx, inc.ChannelID, func(inc *store.Incident) error {
+			inc.SetAcknowledgement(store.Acknowledgement{
+				Role:       role,
+				Assignee:   assignee,
+				AssignedAt: now,
+				Asked:      []string{assignee},
+				AskedAt:    now,
+			})
+			return nil
+		}); err != nil {
+			log.Error().Err(err).Str("role", role).Msg("Could not record request for acknowledgement")
+		}
+	}
+}
+
+// handleAcknowledgeAction - record the acknowledgement of a role by somebody that was asked for it
+func (h *botHandler) handleAcknowledgeAction(ctx context.Context, payload *slack.InteractionCallback, action *slack.BlockAction) error {
+	log := zerolog.Ctx(ctx)
+	userID := payload.User.ID
+	channelID, role, ok := parseAcknowledgeValue(action.Value)
+	if !ok {
+		return fmt.Errorf("invalid acknowledgement %q", action.Value)
+	}
+	var refusal string
+	var ack store.Acknowledgement
+	inc, err := h.incidents.Update(ctx, channelID, func(inc *store.Incident) error {
+		a := inc.Acknowledgement(role)
+		switch {
+		case a == nil || !contains(a.Asked, userID):
+			refusal = h.localizer().MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "NoLongerAskedToAcknowledge",
+					Other: "You are no longer asked to acknowledge the {{.Role}} role of {{.Incident}}"},
+				TemplateData: map[string]string{"Role": role, "Incident": incidentLabel(inc.Number, inc.ChannelID)},
+			})
+		case !a.AckedAt.IsZero():
+			refusal = h.localizer().MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "AlreadyAcknowledged",
+					Other: "<@{{.User}}> already acknowledged the {{.Role}} role of {{.Incident}}"},
+				TemplateData: map[string]string{"User": a.AckedBy, "Role": role, "Incident": incidentLabel(inc.Number, inc.ChannelID)},
+			})
+		default:
+			a.AckedAt = time.Now()
+			a.AckedBy = userID
+			ack = *a
+			inc.AddEvent(a.AckedAt, userID, store.EventAcknowledged, fmt.Sprintf("Acknowledged the %s role", role))
+		}
+		return nil
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionText(valUnknownIncident, false))
+	}
+	if err != nil {
+		return err
+	}
+	if refusal != "" {
+		return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionText(refusal, false))
+	}
+	h.opts.Metrics.RoleAcknowledged(role, inc.Severity, ack.AckedAt.Sub(ack.AssignedAt))
+
+	// Replace the button so that the request can not be acknowledged twice
+	done := h.localizer().MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "YouAcknowledged",
+			Other: ":white_check_mark: You acknowledged the {{.Role}} role of {{.Incident}}"},
+		TemplateData: map[string]string{"Role": role, "Incident": incidentLabel(inc.Number, inc.ChannelID)},
+	})
+	if _, _, _, err := h.slackClient.UpdateMessageContext(ctx, payload.Channel.ID, payload.Message.Timestamp,
+		slack.MsgOptionText(done, false),
+		slack.MsgOptionBlocks(slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, done, false, false), nil, nil))); err != nil {
+		log.Error().Err(err).Msg("Could not update request for acknowledgement")
+	}
+
+	text := h.localizer().MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "Acknowledged",
+			Other: ":white_check_mark: <@{{.User}}> acknowledged the {{.Role}} role"},
+		TemplateData: map[string]string{"User": userID, "Role": role},
+	})
+	if userID != ack.Assignee {
+		// A backup covers for the assignee until the role is handed over
+		text = h.localizer().MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID: "AcknowledgedInPlaceOf",
+				Other: ":white_check_mark: <@{{.User}}> acknowledged the {{.Role}} role in place of <@{{.Assignee}}>, " +
+					"hand the role over with `/devopsbot handover` if they stay unavailable"},
+			TemplateData: map[string]string{"User": userID, "Role": role, "Assignee": ack.Assignee},
+		})
+		// Backups of security incidents are only asked when they are members of its private channel already
+		if !inc.SecurityRelated {
+			if _, err := h.slackClient.InviteUsersToConversationContext(ctx, channelID, userID); err != nil && err.Error() != alreadyInChannel {
+				log.Error().Err(err).Msg("Could not invite backup to incident channel")
+			}
+		}
+	}
+	return h.sendMessage(ctx, channelID, slack.MsgOptionText(text, false))
+}
+
+// escalateAcknowledgements - ask the next backup in the escalation chain to acknowledge the roles of open incidents
+// that were not acknowledged within the window of their severity, and tell the incident channel when nobody is left
+func (h *botHandler) escalateAcknowledgements(ctx context.Context, now time.Time) {
+	log := zerolog.Ctx(ctx)
+	incidents, err := h.incidents.List(ctx, store.ListOptions{Status: store.StatusOpen})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not list open incidents to escalate acknowledgements")
+		return
+	}
+	for _, inc := range incidents {
+		for _, ack := range inc.Acknowledgements {
+			if !ack.AckedAt.IsZero() || ack.Exhausted || now.Before(ack.AskedAt.Add(h.acknowledgementWindow(inc.Severity))) {
+				continue
+			}
+			backup, err := h.nextBackup(ctx, inc, ack)
+			if err != nil {
+				log.Error().Err(err).Str("incident_channel", inc.ChannelID).Msg("Could not find next backup to ask for acknowledgement")
+				continue
+			}
+			if backup == "" {
+				h.acknowledgementsExhausted(ctx, inc, ack)
+			} else {
+				text := h.localizer().MustLocalize(&i18n.LocalizeConfig{
+					DefaultMessage: &i18n.Message{
+						ID: "AcknowledgementEscalated",
+						Other: ":rotating_light: <@{{.Assignee}}> has not acknowledged being the {{.Role}} of {{.Incident}} " +
+							"after {{.Age}}, you are next in the escalation chain\n*Summary:* {{.Summary}}\n" +
+							"*Severity:* {{.Severity}}\nAcknowledge to cover for them"},
+					TemplateData: map[string]string{
+						"Assignee": ack.Assignee,
+						"Role":     ack.Role,
+						"Incident": incidentLabel(inc.Number, inc.ChannelID),
+						"Age":      formatAge(now.Sub(ack.AssignedAt)),
+						"Summary":  inc.Summary,
+						"Severity": inc.Severity,
+					},
+				})
+				if err := h.sendMessage(ctx, backup, slack.MsgOptionText(text, false),
+					slack.MsgOptionBlocks(h.acknowledgeBlocks(text, inc.ChannelID, ack.Role)...)); err != nil {
+					log.Error().Err(err).Str("user_id", backup).Msg("Could not ask backup for acknowledgement")
+				}
+			}
+			if _, err := h.incidents.Update(ctx, inc.ChannelID, func(inc *store.Incident) error {
+				a := inc.Acknowledgement(ack.Role)
+				if a == nil || !a.AckedAt.IsZero() {
+					return nil
+				}
+				if backup == "" {
+					a.Exhausted = true
+					return nil
+				}
+				a.Asked = append(a.Asked, backup)
+				a.AskedAt = now
+				return nil
+			}); err != nil {
+				log.Error().Err(err).Str("incident_channel", inc.ChannelID).Msg("Could not record escalation of acknowledgement")
+			}
+		}
+	}
+}
+
+// nextBackup - the first backup in the escalation chain that was not asked to acknowledge yet, "" if there is none,
+// for security incidents only the backups that are members of its private channel are asked, so that its summary
+// is not sent to anyone outside of it
+func (h *botHandler) nextBackup(ctx context.Context, inc *store.Incident, ack store.Acknowledgement) (string, error) {
+	for _, backup := range h.opts.AcknowledgementBackups {
+		if contains(ack.Asked, backup) {
+			continue
+		}
+		if !inc.SecurityRelated {
+			return backup, nil
+		}
+		member, err := h.isChannelMember(ctx, inc.ChannelID, backup)
+		if err != nil {
+			return "", err
+		}
+		if member {
+			return backup, nil
+		}
+	}
+	return "", nil
+}
+
+// acknowledgementsExhausted - tell the incident channel that nobody acknowledged a role
+func (h *botHandler) acknowledgementsExhausted(ctx context.Context, inc *store.Incident, ack store.Acknowledgement) {
+	mentions := []string{":rotating_light:"}
+	if h.opts.AdminGroupID != "" {
+		mentions = append(mentions, fmt.Sprintf("<!subteam^%s>", h.opts.AdminGroupID))
+	}
+	unanswered := h.localizer().MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "AssigneeDidNotAnswer",
+			Other: "<@{{.Assignee}}> did not answer"},
+		TemplateData: map[string]string{"Assignee": ack.Assignee},
+	})
+	if backups := len(ack.Asked) - 1; backups > 0 {
+		unanswered = h.localizer().MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "BackupsDidNotAnswer",
+				One:   "<@{{.Assignee}}> and {{.Count}} backup did not answer",
+				Other: "<@{{.Assignee}}> and {{.Count}} backups did not answer"},
+			PluralCount:  backups,
+			TemplateData: map[string]interface{}{"Assignee": ack.Assignee, "Count": backups},
+		})
+	}
+	text := h.localizer().MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "NobodyAcknowledged",
+			Other: "{{.Mentions}} Nobody acknowledged the {{.Role}} role, {{.Unanswered}}. Hand the role over with `/devopsbot handover`"},
+		TemplateData: map[string]string{"Mentions": strings.Join(mentions, " "), "Role": ack.Role, "Unanswered": unanswered},
+	})
+	if err := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionText(text, false)); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Could not tell incident channel about unacknowledged role")
+	}
+}
+
+// contains - whether s is in list
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAcknowledgeValue(t *testing.T) {
+	channelID, role, ok := parseAcknowledgeValue(acknowledgeValue("C1", roleCommander))
+	assert.True(t, ok)
+	assert.Equal(t, "C1", channelID)
+	assert.Equal(t, roleCommander, role)
+
+	_, _, ok = parseAcknowledgeValue("C1")
+	assert.False(t, ok)
+}
+
+func TestAcknowledgeRole(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID: "C1",
+		Number:    3,
+		Status:    store.StatusOpen,
+		Severity:  "high",
+		Commander: "U1",
+		Responder: "U2",
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+	}
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+
+	// both role holders get a direct message with a button
+	b.requestAcknowledgements(ctx, inc, roleResponder, roleCommander)
+	require.Len(t, c.messages, 2)
+	assert.Equal(t, "U2", c.messages[0].Get("channel"))
+	assert.Equal(t, "U1", c.messages[1].Get("channel"))
+	assert.Contains(t, c.messages[1].Get("text"), "You are the commander of INC-3 <#C1>")
+	assert.Contains(t, c.messages[1].Get("blocks"), `"action_id":"acknowledge_role","value":"C1 commander"`)
+
+	acknowledge := func(userID string) {
+		w := httptest.NewRecorder()
+		b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+			Type:    slack.InteractionTypeBlockActions,
+			User:    slack.User{ID: userID},
+			Channel: slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "D1"}}},
+			Message: slack.Message{Msg: slack.Msg{Timestamp: "1.000100"}},
+			ActionCallback: slack.ActionCallbacks{
+				BlockActions: []*slack.BlockAction{{BlockID: acknowledgeBlockID, ActionID: actionAcknowledge, Value: acknowledgeValue("C1", roleCommander)}},
+			},
+		}))
+		assert.Equal(t, 200, w.Code)
+	}
+
+	// only the people asked may acknowledge
+	acknowledge("U2")
+	assert.Contains(t, c.messages[2].Get("text"), "You are no longer asked to acknowledge the commander role")
+
+	acknowledge("U1")
+	inc, err = incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	ack := inc.Acknowledgement(roleCommander)
+	require.NotNil(t, ack)
+	assert.Equal(t, "U1", ack.AckedBy)
+	assert.False(t, ack.AckedAt.IsZero())
+	assert.Empty(t, inc.Acknowledgement(roleResponder).AckedBy)
+	assert.Equal(t, store.EventAcknowledged, inc.Timeline[len(inc.Timeline)-1].Kind)
+	require.Len(t, c.updates, 1)
+	assert.Contains(t, c.updates[0].Get("text"), "You acknowledged the commander role")
+	assert.Equal(t, "C1", c.messages[3].Get("channel"))
+	assert.Contains(t, c.messages[3].Get("text"), "<@U1> acknowledged the commander role")
+
+	acknowledge("U1")
+	assert.Contains(t, c.messages[4].Get("text"), "<@U1> already acknowledged the commander role")
+}
+
+func TestEscalateAcknowledgements(t *testing.T) {
+	ctx := context.TODO()
+	assigned := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID: "C1",
+		Number:    3,
+		Status:    store.StatusOpen,
+		Severity:  "high",
+		Commander: "U1",
+		Acknowledgements: []store.Acknowledgement{
+			{Role: roleCommander, Assignee: "U1", AssignedAt: assigned, Asked: []string{"U1"}, AskedAt: assigned},
+		},
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+		opts: Opts{
+			AdminGroupID:           "S1",
+			AcknowledgementWindows: map[string]time.Duration{"high": 5 * time.Minute},
+			AcknowledgementBackups: []string{"U1", "B1", "B2"},
+		},
+	}
+
+	b.escalateAcknowledgements(ctx, assigned.Add(4*time.Minute))
+	assert.Empty(t, c.messages)
+
+	// the backups are asked in order, skipping the assignee
+	b.escalateAcknowledgements(ctx, assigned.Add(5*time.Minute))
+	b.escalateAcknowledgements(ctx, assigned.Add(8*time.Minute))
+	require.Len(t, c.messages, 1)
+	assert.Equal(t, "B1", c.messages[0].Get("channel"))
+	assert.Contains(t, c.messages[0].Get("text"), "<@U1> has not acknowledged being the commander of INC-3 <#C1> after 5m")
+	b.escalateAcknowledgements(ctx, assigned.Add(10*time.Minute))
+	require.Len(t, c.messages, 2)
+	assert.Equal(t, "B2", c.messages[1].Get("channel"))
+
+	// when nobody is left the incident channel is told, once
+	b.escalateAcknowledgements(ctx, assigned.Add(15*time.Minute))
+	b.escalateAcknowledgements(ctx, assigned.Add(30*time.Minute))
+	require.Len(t, c.messages, 3)
+	assert.Equal(t, "C1", c.messages[2].Get("channel"))
+	assert.Contains(t, c.messages[2].Get("text"), "<!subteam^S1> Nobody acknowledged the commander role, <@U1> and 2 backups did not answer")
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"U1", "B1", "B2"}, inc.Acknowledgement(roleCommander).Asked)
+	assert.True(t, inc.Acknowledgement(roleCommander).Exhausted)
+
+	// a backup that was asked may acknowledge in place of the assignee, and is invited
+	w := httptest.NewRecorder()
+	b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+		Type:    slack.InteractionTypeBlockActions,
+		User:    slack.User{ID: "B1"},
+		Channel: slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "D1"}}},
+		ActionCallback: slack.ActionCallbacks{
+			BlockActions: []*slack.BlockAction{{BlockID: acknowledgeBlockID, ActionID: actionAcknowledge, Value: acknowledgeValue("C1", roleCommander)}},
+		},
+	}))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, []string{"B1"}, c.invited)
+	assert.Contains(t, c.messages[3].Get("text"), "<@B1> acknowledged the commander role in place of <@U1>")
+}
+
+func TestEscalateAcknowledgementsSecurityIncident(t *testing.T) {
+	ctx := context.TODO()
+	assigned := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	c := &dummyClient{channelMembers: []string{"U1", "B2"}}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:       "C1",
+		Number:          3,
+		Status:          store.StatusOpen,
+		Severity:        "high",
+		SecurityRelated: true,
+		Commander:       "U1",
+		Acknowledgements: []store.Acknowledgement{
+			{Role: roleCommander, Assignee: "U1", AssignedAt: assigned, Asked: []string{"U1"}, AskedAt: assigned},
+		},
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+		opts: Opts{
+			AcknowledgementWindows: map[string]time.Duration{"high": 5 * time.Minute},
+			AcknowledgementBackups: []string{"B1", "B2"},
+		},
+	}
+
+	// the backups outside of the private channel are skipped, so that they do not see the summary
+	b.escalateAcknowledgements(ctx, assigned.Add(5*time.Minute))
+	require.Len(t, c.messages, 1)
+	assert.Equal(t, "B2", c.messages[0].Get("channel"))
+
+	b.escalateAcknowledgements(ctx, assigned.Add(10*time.Minute))
+	require.Len(t, c.messages, 2)
+	assert.Equal(t, "C1", c.messages[1].Get("channel"))
+	assert.Contains(t, c.messages[1].Get("text"), "Nobody acknowledged the commander role, <@U1> and 1 backup did not answer")
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"U1", "B2"}, inc.Acknowledgement(roleCommander).Asked)
+
+	// the backup is not invited into the private channel when acknowledging
+	w := httptest.NewRecorder()
+	b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+		Type:    slack.InteractionTypeBlockActions,
+		User:    slack.User{ID: "B2"},
+		Channel: slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "D1"}}},
+		ActionCallback: slack.ActionCallbacks{
+			BlockActions: []*slack.BlockAction{{BlockID: acknowledgeBlockID, ActionID: actionAcknowledge, Value: acknowledgeValue("C1", roleCommander)}},
+		},
+	}))
+	assert.Equal(t, 200, w.Code)
+	assert.Empty(t, c.invited)
+	assert.Contains(t, c.messages[2].Get("text"), "<@B2> acknowledged the commander role in place of <@U1>")
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+// defaultAdminRefreshInterval - how often the members of the admin user group are fetched if not configured
+const defaultAdminRefreshInterval = 5 * time.Minute
+
+// refreshAdmins - fetch the members of the admin user group now and then on every interval until ctx is done,
+// so that people removed from the group lose their rights
+func (h *botHandler) refreshAdmins(ctx context.Context) {
+	log := zerolog.Ctx(ctx)
+	interval := h.opts.AdminRefreshInterval
+	if interval <= 0 {
+		interval = defaultAdminRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := h.updateAdmins(ctx, h.opts.AdminGroupID); err != nil {
+			log.Error().Err(err).Msg("Failed to refresh user group members, nobody is an admin until the next refresh")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// adminGroupChanged - fetch the members of the admin user group again when they change, rather than waiting
+// for the next refresh
+func (h *botHandler) adminGroupChanged(ctx context.Context, ev *slack.SubteamMembersChangedEvent) {
+	if ev.SubteamID != h.opts.AdminGroupID {
+		return
+	}
+	log := zerolog.Ctx(ctx)
+	log.Info().Strs("added_users", ev.AddedUsers).Strs("removed_users", ev.RemovedUsers).Msg("User group members changed")
+	if err := h.updateAdmins(ctx, h.opts.AdminGroupID); err != nil {
+		log.Error().Err(err).Msg("Failed to refresh user group members, nobody is an admin until the next refresh")
+	}
+}
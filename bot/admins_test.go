@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminsFailClosed(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{members: []string{"admin"}}
+	b := &botHandler{
+		admins:      &ugMembers{},
+		slackClient: c,
+	}
+	assert.True(t, b.isAdmin(ctx, "admin"))
+
+	// nobody is an admin while the user group can not be fetched
+	c.err = fmt.Errorf("ratelimited")
+	assert.Error(t, b.updateAdmins(ctx, "S1"))
+	assert.False(t, b.isAdmin(ctx, "admin"))
+
+	c.err = nil
+	assert.True(t, b.isAdmin(ctx, "admin"))
+}
+
+func TestRefreshAdmins(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	c := &dummyClient{members: []string{"admin"}}
+	b := &botHandler{
+		admins:      &ugMembers{},
+		slackClient: c,
+		opts:        Opts{AdminGroupID: "S1"},
+	}
+
+	// the user group is fetched right away, then not anymore once ctx is done
+	b.refreshAdmins(ctx)
+	assert.Equal(t, []string{"admin"}, b.admins.members)
+}
+
+func TestAdminGroupChanged(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{members: []string{"admin"}}
+	b := &botHandler{
+		admins:      &ugMembers{},
+		slackClient: c,
+		opts:        Opts{AdminGroupID: "S1"},
+	}
+	assert.True(t, b.isAdmin(ctx, "admin"))
+
+	c.members = []string{"other"}
+	b.adminGroupChanged(ctx, &slack.SubteamMembersChangedEvent{SubteamID: "S2", RemovedUsers: []string{"admin"}})
+	assert.True(t, b.isAdmin(ctx, "admin"))
+
+	b.adminGroupChanged(ctx, &slack.SubteamMembersChangedEvent{SubteamID: "S1", RemovedUsers: []string{"admin"}})
+	assert.False(t, b.isAdmin(ctx, "admin"))
+	assert.True(t, b.isAdmin(ctx, "other"))
+}
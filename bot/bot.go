@@ -3,11 +3,17 @@ package bot
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 
+	"github.com/karl-johan-grahn/devopsbot/metrics"
+	"github.com/karl-johan-grahn/devopsbot/oncall"
+	"github.com/karl-johan-grahn/devopsbot/store"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"github.com/rs/zerolog"
 	"github.com/slack-go/slack"
@@ -16,20 +22,24 @@ import (
 
 type botHandler struct {
 	slackClient SlackClient
+	incidents   store.IncidentStore
 	opts        Opts
 
 	admins *ugMembers
+	events *eventDispatcher
+	// suggestionChannels - the channels of the bot suggested in a modal, by view ID
+	suggestionChannels *ttlCache[[]slack.Channel]
 }
 
 type ugMembers struct {
 	sync.RWMutex
 
 	members []string
+	// loaded - whether members is the result of the last attempt to fetch the user group
+	loaded bool
 }
 
 type Opts struct {
-	// UserAccessToken - the Slack user access token
-	UserAccessToken string
 	// SigningSecret - the signing secret from the Slack app config
 	SigningSecret string
 	// BroadcastChannelID - the ID of the Slack channel the bot will broadcast in
@@ -48,21 +58,79 @@ type Opts struct {
 	IncidentImpactLevels string
 	// Localizer - the localizer to use for the set of language preferences
 	Localizer *i18n.Localizer
+	// IncidentStore - where incident records are kept
+	IncidentStore store.IncidentStore
+	// ReminderClient - deletes the channel reminders added by versions before 0.37.0, they are kept if nil
+	ReminderClient ReminderClient
+	// ChannelNamer - creates and recognises incident channel names, the default naming is used if nil
+	ChannelNamer *ChannelNamer
+	// PostmortemTemplate - the template of the postmortem document uploaded when an incident is resolved,
+	// the built-in template is used if nil
+	PostmortemTemplate *template.Template
+	// Metrics - the incident lifecycle metrics, nothing is recorded if nil
+	Metrics *metrics.IncidentMetrics
+	// Permissions - who may do which actions, DefaultPermissionPolicy is used if nil
+	Permissions PermissionPolicy
+	// AdminRefreshInterval - how often the members of the admin user group are fetched,
+	// defaultAdminRefreshInterval is used if 0
+	AdminRefreshInterval time.Duration
+	// AdminMetrics - the metrics of the admin user group refreshes, nothing is recorded if nil
+	AdminMetrics *metrics.AdminGroupMetrics
+	// ReminderIntervals - how long the commander of an open incident may go without posting a progress update
+	// before being reminded, by lowercase severity with defaultReminderInterval for severities missing from it
+	ReminderIntervals map[string]time.Duration
+	// StaleThresholds - how long an open incident may go without progress before the commander is nudged and
+	// before it is escalated to the admin user group as stale
+	StaleThresholds StaleThresholds
+	// AcknowledgementWindows - how long somebody has to acknowledge a role before the next backup is asked,
+	// by lowercase severity with defaultAcknowledgementWindow for severities missing from it
+	AcknowledgementWindows map[string]time.Duration
+	// AcknowledgementBackups - the user IDs of the escalation chain asked in order when a role is not acknowledged
+	AcknowledgementBackups []string
+	// OnCall - the on-call schedules by team
+	OnCall oncall.Schedules
+	// OnCallResponderTeam - the team whose on-call person is the suggested responder of new incidents,
+	// the only team of OnCall if empty
+	OnCallResponderTeam string
 }
 
-// NewBot - create a new bot handler
-func NewBot(slackClient SlackClient, opts Opts) http.Handler {
+// NewBot - create a new bot handler, which keeps the admin user group up to date and reminds commanders
+// to post progress updates until ctx is done
+func NewBot(ctx context.Context, slackClient SlackClient, opts Opts) http.Handler {
+	h := newBotHandler(ctx, slackClient, opts)
+	return mwVerify(h.opts.SigningSecret, h.routes())
+}
+
+// newBotHandler - create the handler shared by the HTTP endpoints and Socket Mode
+func newBotHandler(ctx context.Context, slackClient SlackClient, opts Opts) *botHandler {
 	h := &botHandler{
 		slackClient: slackClient,
+		incidents:   opts.IncidentStore,
 		opts:        opts,
 		admins:      &ugMembers{},
+		events:      newEventDispatcher(),
+
+		suggestionChannels: newTTLCache[[]slack.Channel](suggestionChannelsTTL),
+	}
+	h.registerEventHandlers()
+
+	if h.opts.AdminGroupID != "" {
+		go h.refreshAdmins(ctx)
 	}
+	if h.incidents != nil {
+		go newScheduler(h.scheduledTasks()...).run(ctx)
+		go h.deleteChannelReminders(ctx)
+	}
+	return h
+}
 
+// routes - the endpoints of the bot, without verification of the requests
+func (h *botHandler) routes() *http.ServeMux {
 	m := http.NewServeMux()
 	m.HandleFunc("/command", h.handleCommand)
 	m.HandleFunc("/interactive", h.handleInteractive)
-
-	return mwVerify(h.opts.SigningSecret, m)
+	m.HandleFunc("/events", h.handleEvents)
+	return m
 }
 
 func (h *botHandler) handleCommand(w http.ResponseWriter, r *http.Request) {
@@ -89,6 +157,13 @@ func (h *botHandler) handleCommand(w http.ResponseWriter, r *http.Request) {
 	bundle.MustLoadMessageFile("active.en.json")
 	bundle.MustLoadMessageFile("active.fr.json")
 	user, err := h.slackClient.GetUserInfoContext(ctx, cmd.UserID)
+	if errors.Is(err, ErrSlackUnavailable) {
+		// No message can be sent either, so answer in the response to the command
+		log.Warn().Err(err).Msg("Slack API unavailable")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(err.Error()))
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = h.errorResponse(ctx, w, cmd, fmt.Sprintf("failed to get user info: %s", err), err)
@@ -113,12 +188,93 @@ func (h *botHandler) handleCommand(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		case "resolve":
-			err = h.cmdResolveIncident(ctx, w, cmd)
+			incident := ""
+			if len(parts) > 1 {
+				incident = parts[1]
+			}
+			err = h.cmdResolveIncident(ctx, w, cmd, incident)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				_ = h.errorResponse(ctx, w, cmd, fmt.Sprintf("cmdResolveIncident failed: %s", err), err)
 			}
 			return
+		case "update":
+			incident := ""
+			if len(parts) > 1 {
+				incident = parts[1]
+			}
+			err = h.cmdUpdateIncident(ctx, w, cmd, incident)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = h.errorResponse(ctx, w, cmd, fmt.Sprintf("cmdUpdateIncident failed: %s", err), err)
+			}
+			return
+		case "escalate", "deescalate":
+			incident := ""
+			if len(parts) > 1 {
+				incident = parts[1]
+			}
+			err = h.cmdEscalateIncident(ctx, w, cmd, incident)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = h.errorResponse(ctx, w, cmd, fmt.Sprintf("cmdEscalateIncident failed: %s", err), err)
+			}
+			return
+		case "handover":
+			incident := ""
+			if len(parts) > 1 {
+				incident = parts[1]
+			}
+			err = h.cmdHandoverIncident(ctx, w, cmd, incident)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = h.errorResponse(ctx, w, cmd, fmt.Sprintf("cmdHandoverIncident failed: %s", err), err)
+			}
+			return
+		case "reopen":
+			incident := ""
+			if len(parts) > 1 {
+				incident = parts[1]
+			}
+			err = h.cmdReopenIncident(ctx, w, cmd, incident)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = h.errorResponse(ctx, w, cmd, fmt.Sprintf("cmdReopenIncident failed: %s", err), err)
+			}
+			return
+		case "status":
+			filter := ""
+			if len(parts) > 1 {
+				filter = parts[1]
+			}
+			err = h.cmdStatus(ctx, w, cmd, filter)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = h.errorResponse(ctx, w, cmd, fmt.Sprintf("cmdStatus failed: %s", err), err)
+			}
+			return
+		case "oncall":
+			team := ""
+			if len(parts) > 1 {
+				team = parts[1]
+			}
+			err = h.cmdOnCall(ctx, w, cmd, team)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = h.errorResponse(ctx, w, cmd, fmt.Sprintf("cmdOnCall failed: %s", err), err)
+			}
+			return
+		case "timeline":
+			args := ""
+			if len(parts) > 1 {
+				args = parts[1]
+			}
+			err = h.cmdTimeline(ctx, w, cmd, args)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = h.errorResponse(ctx, w, cmd, fmt.Sprintf("cmdTimeline failed: %s", err), err)
+			}
+			return
 		default:
 			if err := h.respond(ctx, cmd.ResponseURL, cmd.UserID, slack.ResponseTypeEphemeral,
 				slack.MsgOptionText(h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
@@ -127,7 +283,14 @@ func (h *botHandler) handleCommand(w http.ResponseWriter, r *http.Request) {
 						Other: "These are the available commands:\n" +
 							"> `/devopsbot help` - Get this help\n" +
 							"> `/devopsbot incident` - Declare an incident\n" +
-							"> `/devopsbot resolve` - Resolve an incident"},
+							"> `/devopsbot resolve [INC-n]` - Resolve an incident\n" +
+							"> `/devopsbot reopen [INC-n]` - Reopen a resolved incident\n" +
+							"> `/devopsbot update [INC-n]` - Post a progress update about an incident\n" +
+							"> `/devopsbot escalate [INC-n]` - Change the severity and impact of an incident\n" +
+							"> `/devopsbot handover [INC-n]` - Hand over the commander or responder role of an incident\n" +
+							"> `/devopsbot status [environment or region]` - List open incidents\n" +
+							"> `/devopsbot oncall [team]` - Show who is on call now and next\n" +
+							"> `/devopsbot timeline [INC-n] [markdown or json]` - Export the timeline of an incident, by default the one of the channel you are in"},
 				}), false),
 				slack.MsgOptionAttachments(),
 			); err != nil {
@@ -145,6 +308,9 @@ func (h *botHandler) handleCommand(w http.ResponseWriter, r *http.Request) {
 
 // cmdIncident - general handler for /devops incident commands
 func (h *botHandler) cmdIncident(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand) error {
+	if refusal, ok := h.authorize(ctx, cmd.UserID, ActionDeclare, nil); !ok {
+		return h.errorResponse(ctx, w, cmd, refusal, nil)
+	}
 	titleText := slack.NewTextBlockObject(slack.PlainTextType,
 		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
 			DefaultMessage: &i18n.Message{
@@ -172,52 +338,10 @@ func (h *botHandler) cmdIncident(ctx context.Context, w http.ResponseWriter, cmd
 		}), false, false)
 	contextBlock := slack.NewContextBlock("context", contextText)
 
-	broadcastChLabel := slack.NewTextBlockObject(slack.PlainTextType,
-		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
-			DefaultMessage: &i18n.Message{
-				ID:    "BroadcastChannel",
-				Other: "Broadcast channel"},
-		}), false, false)
-	// TODO: Only get one page of results for now, implement pagination later if needed
-	authTestResp, _ := h.slackClient.AuthTestContext(ctx)
-	channels, _, err := h.slackClient.GetConversationsForUserContext(ctx, &slack.GetConversationsForUserParameters{
-		UserID:          authTestResp.UserID,
-		Limit:           100,
-		ExcludeArchived: true,
-	})
+	broadcastChBlock, err := h.broadcastChannelBlock(ctx)
 	if err != nil {
-		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to get conversations for bot: %s", err), err)
+		return h.modalErrorResponse(ctx, w, cmd, err)
 	}
-	if channels == nil {
-		return h.errorResponse(ctx, w, cmd, "Bot must be added to a channel for broadcasting messages", nil)
-	}
-	channelIDs := []string{}
-	var botInBroadcastChannel = false
-	for i := range channels {
-		channelIDs = append(channelIDs, channels[i].ID)
-		if channels[i].ID == h.opts.BroadcastChannelID {
-			botInBroadcastChannel = true
-		}
-	}
-	if !botInBroadcastChannel {
-		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("The bot is not part of the configured broadcast channel <#%s>, invite it there first", h.opts.BroadcastChannelID), nil)
-	}
-	broadcastChannel, err := h.slackClient.GetConversationInfoContext(ctx, h.opts.BroadcastChannelID, false)
-	if broadcastChannel.IsArchived {
-		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("The configured broadcast channel <#%s> is archived, update the configuration to use an open broadcast channel", h.opts.BroadcastChannelID), err)
-	}
-	botChannels := createOptionBlockObjects(channelIDs, "channel")
-	broadcastChOption := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, nil, "broadcast_channel", botChannels...)
-	initialChannelLabel := slack.NewTextBlockObject(slack.PlainTextType,
-		fmt.Sprintf("<#%s>", h.opts.BroadcastChannelID), false, false)
-	broadcastChOption.InitialOption = slack.NewOptionBlockObject(h.opts.BroadcastChannelID, initialChannelLabel, nil)
-	broadcastChHint := slack.NewTextBlockObject(slack.PlainTextType,
-		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
-			DefaultMessage: &i18n.Message{
-				ID:    "BroadcastChannelHint",
-				Other: "The channels listed are the ones that the bot has been added to as a user"},
-		}), false, false)
-	broadcastChBlock := slack.NewInputBlock("broadcast_channel", broadcastChLabel, broadcastChHint, broadcastChOption)
 
 	// Only the inputs in input blocks will be included in view_submission’s view.state.values: https://slack.dev/java-slack-sdk/guides/modals
 	incidentNameText := slack.NewTextBlockObject(slack.PlainTextType,
@@ -227,8 +351,8 @@ func (h *botHandler) cmdIncident(ctx context.Context, w http.ResponseWriter, cmd
 				Other: "Incident name"},
 		}), false, false)
 	incidentNameElement := slack.NewPlainTextInputBlockElement(incidentNameText, "incident_name")
-	// Name will be prefixed with "inc_" and postfixed with "_<date>" so keep it shorter than the maximum 80 characters
-	incidentNameElement.MaxLength = 60
+	// The name is the slug of the channel name, so keep it short enough for the whole name to fit
+	incidentNameElement.MaxLength = h.channelNamer().MaxSlugLength()
 	incidentNameElement.DispatchActionConfig = &slack.DispatchActionConfig{
 		TriggerActionsOn: []string{"on_character_entered"},
 	}
@@ -236,7 +360,8 @@ func (h *botHandler) cmdIncident(ctx context.Context, w http.ResponseWriter, cmd
 		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
 			DefaultMessage: &i18n.Message{
 				ID:    "IncidentNameHint",
-				Other: "Incident names may only contain lowercase letters, numbers, hyphens, and underscores, and must be 60 characters or less"},
+				Other: "Incident names may only contain lowercase letters, numbers, hyphens, and underscores, and must be {{.MaxLength}} characters or less"},
+			TemplateData: map[string]int{"MaxLength": incidentNameElement.MaxLength},
 		}), false, false)
 	incidentNameBlock := slack.NewInputBlock("incident_name", incidentNameText, incidentNameHint, incidentNameElement)
 	incidentNameBlock.DispatchAction = true
@@ -265,6 +390,8 @@ func (h *botHandler) cmdIncident(ctx context.Context, w http.ResponseWriter, cmd
 				Other: "Responder"},
 		}), false, false)
 	responderOption := slack.NewOptionsSelectBlockElement(slack.OptTypeUser, responderText, "incident_responder")
+	// Suggest whoever is on call rather than whoever the declarer knows
+	responderOption.InitialUser = h.onCallResponder(time.Now())
 	responderHint := slack.NewTextBlockObject(slack.PlainTextType,
 		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
 			DefaultMessage: &i18n.Message{
@@ -401,8 +528,32 @@ func (h *botHandler) cmdIncident(ctx context.Context, w http.ResponseWriter, cmd
 	return nil
 }
 
-// cmdResolveIncident - handler for resolving incident
-func (h *botHandler) cmdResolveIncident(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand) error {
+// cmdResolveIncident - handler for resolving incident, optionally the one given as argument
+func (h *botHandler) cmdResolveIncident(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, incident string) error {
+	initialChannelID, err := h.incidentArgChannel(ctx, incident)
+	if errors.Is(err, store.ErrNotFound) {
+		return h.errorResponse(ctx, w, cmd, h.unknownIncidentText(incident), nil)
+	}
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to get incident: %s", err), err)
+	}
+
+	modalVReq, err := h.resolveIncidentModal(ctx, initialChannelID)
+	if err != nil {
+		return h.modalErrorResponse(ctx, w, cmd, err)
+	}
+
+	_, err = h.slackClient.OpenViewContext(ctx, cmd.TriggerID, modalVReq)
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Error opening view: %s", err), err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// resolveIncidentModal - the modal for resolving an incident, with the given incident channel chosen if any
+func (h *botHandler) resolveIncidentModal(ctx context.Context, initialChannelID string) (slack.ModalViewRequest, error) {
 	titleText := slack.NewTextBlockObject(slack.PlainTextType,
 		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
 			DefaultMessage: &i18n.Message{
@@ -430,74 +581,12 @@ func (h *botHandler) cmdResolveIncident(ctx context.Context, w http.ResponseWrit
 		}), false, false)
 	contextBlock := slack.NewContextBlock("context", contextText)
 
-	broadcastChLabel := slack.NewTextBlockObject(slack.PlainTextType,
-		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
-			DefaultMessage: &i18n.Message{
-				ID:    "BroadcastChannel",
-				Other: "Broadcast channel"},
-		}), false, false)
-	// TODO: Only get one page of results for now, implement pagination later if needed
-	authTestResp, _ := h.slackClient.AuthTestContext(ctx)
-	channels, _, err := h.slackClient.GetConversationsForUserContext(ctx, &slack.GetConversationsForUserParameters{
-		UserID:          authTestResp.UserID,
-		Limit:           100,
-		ExcludeArchived: true,
-	})
+	broadcastChBlock, err := h.broadcastChannelBlock(ctx)
 	if err != nil {
-		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to get conversations for bot: %s", err), err)
-	}
-	if channels == nil {
-		return h.errorResponse(ctx, w, cmd, "Bot must be added to a channel for broadcasting messages", nil)
+		return slack.ModalViewRequest{}, err
 	}
-	channelIDs := []string{}
-	var botInBroadcastChannel = false
-	for i := range channels {
-		channelIDs = append(channelIDs, channels[i].ID)
-		if channels[i].ID == h.opts.BroadcastChannelID {
-			botInBroadcastChannel = true
-		}
-	}
-	if !botInBroadcastChannel {
-		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("The bot is not part of the configured broadcast channel <#%s>, invite it there first", h.opts.BroadcastChannelID), nil)
-	}
-	broadcastChannel, err := h.slackClient.GetConversationInfoContext(ctx, h.opts.BroadcastChannelID, false)
-	if broadcastChannel.IsArchived {
-		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("The configured broadcast channel <#%s> is archived, update the configuration to use an open broadcast channel", h.opts.BroadcastChannelID), err)
-	}
-	botChannels := createOptionBlockObjects(channelIDs, "channel")
-	broadcastChOption := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, nil, "broadcast_channel", botChannels...)
-	initialChannelLabel := slack.NewTextBlockObject(slack.PlainTextType,
-		fmt.Sprintf("<#%s>", h.opts.BroadcastChannelID), false, false)
-	broadcastChOption.InitialOption = slack.NewOptionBlockObject(h.opts.BroadcastChannelID, initialChannelLabel, nil)
-	broadcastChHint := slack.NewTextBlockObject(slack.PlainTextType,
-		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
-			DefaultMessage: &i18n.Message{
-				ID:    "BroadcastChannelHint",
-				Other: "The channels listed are the ones that the bot has been added to as a user"},
-		}), false, false)
-	broadcastChBlock := slack.NewInputBlock("broadcast_channel", broadcastChLabel, broadcastChHint, broadcastChOption)
 
-	incChanText := slack.NewTextBlockObject(slack.PlainTextType,
-		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
-			DefaultMessage: &i18n.Message{
-				ID:    "Incident",
-				Other: "Incident"},
-		}), false, false)
-	incChanOption := slack.NewOptionsSelectBlockElement(slack.OptTypeConversations, incChanText, "incident_channel")
-	incChanOption.DefaultToCurrentConversation = true
-	incChanOption.Filter = &slack.SelectBlockElementFilter{
-		Include:                       []string{"public"},
-		ExcludeExternalSharedChannels: false,
-		ExcludeBotUsers:               false,
-	}
-	incChanHint := slack.NewTextBlockObject(slack.PlainTextType,
-		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
-			DefaultMessage: &i18n.Message{
-				ID:    "IncidentChannelNamePattern",
-				Other: "Choose a channel that starts with 'inc_'"},
-		}), false, false)
-	incChanBlock := slack.NewInputBlock("incident_channel", incChanText, incChanHint, incChanOption)
-	incChanBlock.DispatchAction = true
+	incChanBlock := h.incidentChannelBlock(initialChannelID)
 
 	archiveTxt := slack.NewTextBlockObject(slack.PlainTextType,
 		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
@@ -548,14 +637,67 @@ func (h *botHandler) cmdResolveIncident(ctx context.Context, w http.ResponseWrit
 	modalVReq.Blocks = blocks
 	modalVReq.ClearOnClose = true
 	modalVReq.CallbackID = "resolve_incident"
+	return modalVReq, nil
+}
 
-	_, err = h.slackClient.OpenViewContext(ctx, cmd.TriggerID, modalVReq)
+// incidentArgChannel - the channel of the incident given as command argument, for example INC-42,
+// empty if no incident is given. Fails with store.ErrNotFound if there is no such incident.
+func (h *botHandler) incidentArgChannel(ctx context.Context, arg string) (string, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return "", nil
+	}
+	number, ok := parseIncidentRef(arg)
+	if !ok {
+		return "", fmt.Errorf("%q: %w", arg, store.ErrNotFound)
+	}
+	inc, err := h.incidents.GetByNumber(ctx, number)
 	if err != nil {
-		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Error opening view: %s", err), err)
+		return "", err
 	}
+	return inc.ChannelID, nil
+}
 
-	w.WriteHeader(http.StatusOK)
-	return nil
+// unknownIncidentText - the response to a command argument that is not an incident
+func (h *botHandler) unknownIncidentText(arg string) string {
+	return h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "UnknownIncident",
+			Other: "There is no incident {{.Incident}}"},
+		TemplateData: map[string]string{"Incident": strings.TrimSpace(arg)},
+	})
+}
+
+// incidentChannelBlock - input block for choosing an incident channel, defaulting to
+// the given channel or to the current channel if none is given
+func (h *botHandler) incidentChannelBlock(initialChannelID string) *slack.InputBlock {
+	incChanText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Incident",
+				Other: "Incident"},
+		}), false, false)
+	incChanOption := slack.NewOptionsSelectBlockElement(slack.OptTypeConversations, incChanText, "incident_channel")
+	if initialChannelID != "" {
+		incChanOption.InitialConversation = initialChannelID
+	} else {
+		incChanOption.DefaultToCurrentConversation = true
+	}
+	incChanOption.Filter = &slack.SelectBlockElementFilter{
+		Include:                       []string{"public", "private"},
+		ExcludeExternalSharedChannels: false,
+		ExcludeBotUsers:               false,
+	}
+	incChanHint := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "IncidentChannelNamePattern",
+				Other: "Choose an incident channel, named like #{{.Example}}"},
+			TemplateData: map[string]string{"Example": h.channelNamer().Example()},
+		}), false, false)
+	incChanBlock := slack.NewInputBlock("incident_channel", incChanText, incChanHint, incChanOption)
+	incChanBlock.DispatchAction = true
+	return incChanBlock
 }
 
 // createOptionBlockObjects - utility function for generating option block objects
@@ -585,6 +727,31 @@ func (h *botHandler) errorResponse(ctx context.Context, w http.ResponseWriter, c
 	return err
 }
 
+// modalError - a reason for not being able to open a modal, with the text to show to the user
+type modalError struct {
+	text string
+	err  error
+}
+
+// Error - conform to the error interface
+func (e *modalError) Error() string {
+	return e.text
+}
+
+// Unwrap - the underlying error, if any
+func (e *modalError) Unwrap() error {
+	return e.err
+}
+
+// modalErrorResponse - send ephemeral error response via Slack UI for a modal that could not be built
+func (h *botHandler) modalErrorResponse(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, err error) error {
+	var merr *modalError
+	if errors.As(err, &merr) {
+		return h.errorResponse(ctx, w, cmd, merr.text, merr.err)
+	}
+	return h.errorResponse(ctx, w, cmd, err.Error(), err)
+}
+
 // respond - a simplified way to respond
 func (h *botHandler) respond(ctx context.Context, responseURL, channelID, responseType string, options ...slack.MsgOption) error {
 	log := zerolog.Ctx(ctx)
@@ -598,11 +765,15 @@ func (h *botHandler) respond(ctx context.Context, responseURL, channelID, respon
 	return nil
 }
 
+// isAdmin - whether a user is a member of the admin user group. Nobody is an admin while the group can not be fetched.
 func (h *botHandler) isAdmin(ctx context.Context, userID string) bool {
 	log := zerolog.Ctx(ctx)
 
-	if len(h.admins.members) == 0 {
-		log.Info().Msg("User group empty, updating internal list")
+	h.admins.RLock()
+	loaded := h.admins.loaded
+	h.admins.RUnlock()
+	if !loaded {
+		log.Info().Msg("User group not loaded, updating internal list")
 		err := h.updateAdmins(ctx, h.opts.AdminGroupID)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to get user group members")
@@ -620,12 +791,17 @@ func (h *botHandler) isAdmin(ctx context.Context, userID string) bool {
 	return false
 }
 
-// updateAdmins - update the bot's internal admin list
+// updateAdmins - update the bot's internal admin list, which is emptied if the user group can not be fetched
 func (h *botHandler) updateAdmins(ctx context.Context, userGroup string) error {
 	log := zerolog.Ctx(ctx)
 
 	members, err := h.slackClient.GetUserGroupMembersContext(ctx, userGroup)
 	if err != nil {
+		h.admins.Lock()
+		defer h.admins.Unlock()
+		h.admins.members = nil
+		h.admins.loaded = false
+		h.opts.AdminMetrics.AdminGroupRefreshFailed()
 		return fmt.Errorf("failed to get members of group %q: %w", userGroup, err)
 	}
 	log.Info().Strs("members", members).Msg("Updating internal user group list")
@@ -633,5 +809,18 @@ func (h *botHandler) updateAdmins(ctx context.Context, userGroup string) error {
 	h.admins.Lock()
 	defer h.admins.Unlock()
 	h.admins.members = members
+	h.admins.loaded = true
+	h.opts.AdminMetrics.AdminGroupRefreshed(len(members), time.Now())
 	return nil
 }
+
+// englishLocalizer - localizes the default messages, for when no command has set a localizer yet
+var englishLocalizer = i18n.NewLocalizer(i18n.NewBundle(language.English), language.English.String())
+
+// localizer - the localizer of the latest command, or an English one when there is none
+func (h *botHandler) localizer() *i18n.Localizer {
+	if h.opts.Localizer == nil {
+		return englishLocalizer
+	}
+	return h.opts.Localizer
+}
@@ -3,14 +3,20 @@ package bot
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/karl-johan-grahn/devopsbot/store"
 	"github.com/slack-go/slack"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUpdateAdmins(t *testing.T) {
@@ -62,6 +68,16 @@ func newPostRequest(body *bytes.Buffer) *http.Request {
 	return r
 }
 
+func newInteractiveRequest(t *testing.T, payload slack.InteractionCallback) *http.Request {
+	b, err := json.Marshal(payload)
+	require.NoError(t, err)
+	v := url.Values{}
+	v.Set("payload", string(b))
+	r := httptest.NewRequest(http.MethodPost, "/interactive", bytes.NewBufferString(v.Encode()))
+	r.Header.Set("content-type", "application/x-www-form-urlencoded")
+	return r
+}
+
 func TestHandleCommand(t *testing.T) {
 	c := &dummyClient{}
 	c.User = &slack.User{
@@ -156,24 +172,49 @@ func TestCreateOptionBlockObjects(t *testing.T) {
 	}, optionBlockObjects)
 }
 
+func newTestStore(t *testing.T) store.IncidentStore {
+	s, err := store.NewFileStore(filepath.Join(t.TempDir(), "incidents.json"))
+	require.NoError(t, err)
+	return s
+}
+
+// messageBlocks - decode the blocks of a message sent via the dummy client
+func messageBlocks(t *testing.T, response url.Values) []slack.Block {
+	blocks := slack.Blocks{}
+	require.NoError(t, json.Unmarshal([]byte(response.Get("blocks")), &blocks))
+	return blocks.BlockSet
+}
+
 type dummyClient struct {
 	members          []string
 	err              error
 	response         url.Values
+	messages         []url.Values
 	viewResponse     *slack.ViewResponse
 	Channel          *slack.Channel
-	Reminder         *slack.Reminder
 	AuthTestResponse *slack.AuthTestResponse
 	User             *slack.User
-	Channels         []slack.Channel
-	NextCursor       string
+	ChannelPages     [][]slack.Channel
+	uploads          []slack.FileUploadParameters
+	updates          []url.Values
+	unarchived       []string
+	invited          []string
+	views            []slack.ModalViewRequest
+	channelMembers   []string
 }
 
 var _ SlackClient = &dummyClient{}
 
 func (c *dummyClient) SendMessageContext(ctx context.Context, channelID string, options ...slack.MsgOption) (_channel, _timestamp, _text string, err error) {
 	_, c.response, err = slack.UnsafeApplyMsgOptions("", channelID, "", options...)
-	return "", "", "", err
+	c.messages = append(c.messages, c.response)
+	return channelID, fmt.Sprintf("%d.000100", len(c.messages)), "", err
+}
+
+func (c *dummyClient) UpdateMessageContext(ctx context.Context, channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	c.updates = append(c.updates, values)
+	return channelID, timestamp, "", err
 }
 
 func (c *dummyClient) GetUserGroupMembersContext(ctx context.Context, userGroup string) ([]string, error) {
@@ -181,6 +222,7 @@ func (c *dummyClient) GetUserGroupMembersContext(ctx context.Context, userGroup
 }
 
 func (c *dummyClient) OpenViewContext(ctx context.Context, triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error) {
+	c.views = append(c.views, view)
 	return c.viewResponse, c.err
 }
 
@@ -200,6 +242,11 @@ func (c *dummyClient) ArchiveConversationContext(ctx context.Context, channelID
 	return c.err
 }
 
+func (c *dummyClient) UnArchiveConversationContext(ctx context.Context, channelID string) error {
+	c.unarchived = append(c.unarchived, channelID)
+	return c.err
+}
+
 func (c *dummyClient) SetPurposeOfConversationContext(ctx context.Context, channelID, purpose string) (*slack.Channel, error) {
 	return c.Channel, c.err
 }
@@ -209,11 +256,12 @@ func (c *dummyClient) SetTopicOfConversationContext(ctx context.Context, channel
 }
 
 func (c *dummyClient) InviteUsersToConversationContext(ctx context.Context, channelID string, users ...string) (*slack.Channel, error) {
+	c.invited = append(c.invited, users...)
 	return c.Channel, c.err
 }
 
-func (c *dummyClient) AddChannelReminder(channelID string, text string, time string) (*slack.Reminder, error) {
-	return c.Reminder, c.err
+func (c *dummyClient) GetUsersInConversationContext(ctx context.Context, params *slack.GetUsersInConversationParameters) ([]string, string, error) {
+	return c.channelMembers, "", c.err
 }
 
 func (c *dummyClient) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
@@ -224,6 +272,27 @@ func (c *dummyClient) AuthTestContext(ctx context.Context) (*slack.AuthTestRespo
 	return c.AuthTestResponse, c.err
 }
 
+// GetConversationsForUserContext - a page of ChannelPages per call, the cursor is the index of the page
 func (c *dummyClient) GetConversationsForUserContext(ctx context.Context, params *slack.GetConversationsForUserParameters) ([]slack.Channel, string, error) {
-	return c.Channels, c.NextCursor, c.err
+	page := 0
+	if params.Cursor != "" {
+		page, _ = strconv.Atoi(params.Cursor)
+	}
+	if page >= len(c.ChannelPages) {
+		return nil, "", c.err
+	}
+	next := ""
+	if page+1 < len(c.ChannelPages) {
+		next = strconv.Itoa(page + 1)
+	}
+	return c.ChannelPages[page], next, c.err
+}
+
+func (c *dummyClient) UploadFileContext(ctx context.Context, params slack.FileUploadParameters) (*slack.File, error) {
+	c.uploads = append(c.uploads, params)
+	return &slack.File{}, c.err
+}
+
+func (c *dummyClient) GetPermalinkContext(ctx context.Context, params *slack.PermalinkParameters) (string, error) {
+	return fmt.Sprintf("https://example.slack.com/archives/%s/p%s", params.Channel, strings.ReplaceAll(params.Ts, ".", "")), c.err
 }
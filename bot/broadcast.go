@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// broadcastActionsBlockID - the block with the buttons on the broadcast announcement of an incident
+	broadcastActionsBlockID = "incident_broadcast_actions"
+
+	actionJoinIncident      = "join_incident"
+	actionSubscribeIncident = "subscribe_incident"
+	actionAffectedIncident  = "affected_incident"
+)
+
+// declarationText - the announcement of an incident in the broadcast channel
+func declarationText(inc *store.Incident) string {
+	var securityMessage string
+	if inc.SecurityRelated {
+		securityMessage = securityIncidentMessage
+	}
+	return fmt.Sprintf(":rotating_siren: Incident %s has been declared by <@%s>\n"+
+		"*Incident summary:* %s\n"+
+		"*Environment affected:* %s\n"+
+		"*Region affected:* %s\n"+
+		"*Severity:* %s\n"+
+		"*Impact:* %s\n"+
+		"*Responder:* <@%s>\n"+
+		"*Commander:* <@%s>\n"+
+		"*Incident channel:* <#%s>\n"+
+		securityMessage,
+		incidentRef(inc.Number), inc.Declarer, inc.Summary, strings.Join(inc.Environments, ", "),
+		strings.Join(inc.Regions, ", "), inc.Severity, inc.Impact,
+		inc.Responder, inc.Commander,
+		inc.ChannelID)
+}
+
+// declarationBlocks - the announcement of an incident with buttons to join, subscribe, and report being affected
+func (h *botHandler) declarationBlocks(inc *store.Incident) []slack.Block {
+	buttons := []slack.BlockElement{}
+	// Security related incidents are available by invitation only
+	if !inc.SecurityRelated {
+		buttons = append(buttons, slack.NewButtonBlockElement(actionJoinIncident, inc.ChannelID,
+			slack.NewTextBlockObject(slack.PlainTextType, h.localizer().MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "JoinIncidentChannel",
+					Other: "Join incident channel"},
+			}), false, false)))
+	}
+	buttons = append(buttons,
+		slack.NewButtonBlockElement(actionSubscribeIncident, inc.ChannelID,
+			slack.NewTextBlockObject(slack.PlainTextType, h.localizer().MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "SubscribeToUpdates",
+					Other: "Subscribe to updates"},
+			}), false, false)),
+		slack.NewButtonBlockElement(actionAffectedIncident, inc.ChannelID,
+			slack.NewTextBlockObject(slack.PlainTextType, h.localizer().MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "AffectedToo",
+					Other: "I'm affected too"},
+			}), false, false)),
+	)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, declarationText(inc), false, false), nil, nil),
+		slack.NewActionBlock(broadcastActionsBlockID, buttons...),
+	}
+	if n := len(inc.AffectedUsers); n > 0 {
+		affected := h.localizer().MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "PeopleAffected",
+				One:   ":raised_hand: {{.Count}} person affected",
+				Other: ":raised_hand: {{.Count}} people affected"},
+			PluralCount:  n,
+			TemplateData: map[string]int{"Count": n},
+		})
+		blocks = append(blocks, slack.NewContextBlock("incident_affected",
+			slack.NewTextBlockObject(slack.MarkdownType, affected, false, false)))
+	}
+	return blocks
+}
+
+// handleBroadcastAction - handle a click on one of the buttons of the broadcast announcement of an incident
+func (h *botHandler) handleBroadcastAction(ctx context.Context, payload *slack.InteractionCallback, action *slack.BlockAction) error {
+	log := zerolog.Ctx(ctx)
+	userID := payload.User.ID
+	channelID := action.Value
+	inc, err := h.incidents.Get(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
+		return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionPostEphemeral(userID),
+			slack.MsgOptionText(valUnknownIncident, false))
+	}
+	if err != nil {
+		return err
+	}
+
+	switch action.ActionID {
+	case actionJoinIncident:
+		if inc.SecurityRelated {
+			return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionPostEphemeral(userID),
+				slack.MsgOptionText(securityIncidentMessage, false))
+		}
+		if _, err := h.slackClient.InviteUsersToConversationContext(ctx, channelID, userID); err != nil && err.Error() != alreadyInChannel {
+			return fmt.Errorf("failed to invite user to incident channel: %w", err)
+		}
+		return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionPostEphemeral(userID),
+			slack.MsgOptionText(h.localizer().MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "JoinedIncidentChannel",
+					Other: "You have joined <#{{.Channel}}>"},
+				TemplateData: map[string]string{"Channel": channelID},
+			}), false))
+	case actionSubscribeIncident:
+		if _, err := h.incidents.Update(ctx, channelID, func(inc *store.Incident) error {
+			inc.Subscribers = appendUnique(inc.Subscribers, userID)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionPostEphemeral(userID),
+			slack.MsgOptionText(h.localizer().MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "SubscribedToUpdates",
+					Other: "You will get a direct message about updates on {{.Incident}}"},
+				TemplateData: map[string]string{"Incident": incidentLabel(inc.Number, channelID)},
+			}), false))
+	case actionAffectedIncident:
+		added := false
+		inc, err = h.incidents.Update(ctx, channelID, func(inc *store.Incident) error {
+			n := len(inc.AffectedUsers)
+			inc.AffectedUsers = appendUnique(inc.AffectedUsers, userID)
+			added = len(inc.AffectedUsers) > n
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !added {
+			return nil
+		}
+		if inc.BroadcastTS == "" {
+			log.Warn().Str("incident_channel", channelID).Msg("Broadcast announcement of incident is unknown")
+			return nil
+		}
+		if _, _, _, err := h.slackClient.UpdateMessageContext(ctx, inc.BroadcastChannelID, inc.BroadcastTS,
+			slack.MsgOptionText(declarationText(inc), false),
+			slack.MsgOptionBlocks(h.declarationBlocks(inc)...)); err != nil {
+			return fmt.Errorf("failed to update broadcast announcement: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown broadcast action %q", action.ActionID)
+}
+
+// notifySubscribers - send a direct message to the people subscribed to updates on an incident
+func (h *botHandler) notifySubscribers(ctx context.Context, inc *store.Incident, text string) {
+	log := zerolog.Ctx(ctx)
+	for _, userID := range inc.Subscribers {
+		if err := h.sendMessage(ctx, userID, slack.MsgOptionText(text, false)); err != nil {
+			log.Error().Err(err).Str("user_id", userID).Msg("Could not notify subscriber")
+		}
+	}
+}
+
+// appendUnique - append s to list unless it is already in it
+func appendUnique(list []string, s string) []string {
+	for _, l := range list {
+		if l == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclarationBlocks(t *testing.T) {
+	inc := &store.Incident{ChannelID: "C1", Number: 4}
+	b := &botHandler{}
+	blocks := b.declarationBlocks(inc)
+	require.Len(t, blocks, 2)
+	assert.Contains(t, blocks[0].(*slack.SectionBlock).Text.Text, "Incident INC-4 has been declared")
+	assert.Len(t, blocks[1].(*slack.ActionBlock).Elements.ElementSet, 3)
+
+	// security related incidents can not be joined, and the affected people are counted
+	inc.SecurityRelated = true
+	inc.AffectedUsers = []string{"U1", "U2"}
+	blocks = b.declarationBlocks(inc)
+	require.Len(t, blocks, 3)
+	assert.Len(t, blocks[1].(*slack.ActionBlock).Elements.ElementSet, 2)
+	assert.Contains(t, blocks[2].(*slack.ContextBlock).ContextElements.Elements[0].(*slack.TextBlockObject).Text, "2 people affected")
+}
+
+func TestHandleBroadcastAction(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:          "C1",
+		BroadcastChannelID: "B1",
+		BroadcastTS:        "1.000100",
+		Status:             store.StatusOpen,
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+	}
+	click := func(userID, actionID string) {
+		w := httptest.NewRecorder()
+		b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+			Type:    slack.InteractionTypeBlockActions,
+			User:    slack.User{ID: userID},
+			Channel: slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "B1"}}},
+			ActionCallback: slack.ActionCallbacks{
+				BlockActions: []*slack.BlockAction{{BlockID: broadcastActionsBlockID, ActionID: actionID, Value: "C1"}},
+			},
+		}))
+		assert.Equal(t, 200, w.Code)
+	}
+
+	click("U1", actionJoinIncident)
+	assert.Equal(t, []string{"U1"}, c.invited)
+
+	click("U1", actionSubscribeIncident)
+	click("U1", actionSubscribeIncident)
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"U1"}, inc.Subscribers)
+
+	// only the first click of a person counts
+	click("U2", actionAffectedIncident)
+	click("U2", actionAffectedIncident)
+	require.Len(t, c.updates, 1)
+	assert.Equal(t, "B1", c.updates[0].Get("channel"))
+	assert.Contains(t, c.updates[0].Get("blocks"), "1 person affected")
+
+	// subscribers get a direct message about updates
+	c.messages = nil
+	b.doUpdateTasks(ctx, &updateParams{
+		incidentChannel: "C1",
+		incidentStatus:  "Monitoring",
+		incidentChanges: "Rolled back",
+		nextUpdateIn:    time.Hour,
+		incidentUpdater: "U3",
+	})
+	require.Len(t, c.messages, 3)
+	assert.Equal(t, "U1", c.messages[2].Get("channel"))
+}
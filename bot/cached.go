@@ -0,0 +1,211 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/metrics"
+	"github.com/slack-go/slack"
+)
+
+// The caches of cachingSlackClient, also used as metric labels
+const (
+	cacheAuthTest      = "auth_test"
+	cacheUserInfo      = "user_info"
+	cacheChannelInfo   = "channel_info"
+	cacheConversations = "bot_conversations"
+)
+
+// ttlCache - values that expire a while after they are added. Expired values are removed when they are read,
+// and all of them at most once per ttl when a value is added, so that values that are not read again do not pile up.
+type ttlCache[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]ttlEntry[V]
+	// nextSweep - when the expired values are removed next
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, entries: map[string]ttlEntry[V]{}, now: time.Now}
+}
+
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *ttlCache[V]) set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if !now.Before(c.nextSweep) {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = now.Add(c.ttl)
+	}
+	c.entries[key] = ttlEntry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+func (c *ttlCache[V]) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+func (c *ttlCache[V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]ttlEntry[V]{}
+}
+
+// conversationsPage - one page of GetConversationsForUserContext
+type conversationsPage struct {
+	channels []slack.Channel
+	cursor   string
+}
+
+// cachingSlackClient - a SlackClient that keeps the answers to lookups that are done for every command for a while.
+// The other calls go straight to the wrapped client.
+type cachingSlackClient struct {
+	SlackClient
+	metrics *metrics.SlackCacheMetrics
+
+	authTest      *ttlCache[*slack.AuthTestResponse]
+	users         *ttlCache[*slack.User]
+	channels      *ttlCache[*slack.Channel]
+	conversations *ttlCache[conversationsPage]
+}
+
+// NewCachingSlackClient - wrap a SlackClient so that the bot identity, user info, channel info and the channels
+// of the bot are looked up at most once per ttl. Channel info and the channels of the bot are looked up again
+// after the bot creates, archives or changes a channel, and channel info after the bot invites people to it.
+func NewCachingSlackClient(client SlackClient, ttl time.Duration, m *metrics.SlackCacheMetrics) SlackClient {
+	return newCachingSlackClient(client, ttl, m)
+}
+
+func newCachingSlackClient(client SlackClient, ttl time.Duration, m *metrics.SlackCacheMetrics) *cachingSlackClient {
+	return &cachingSlackClient{
+		SlackClient:   client,
+		metrics:       m,
+		authTest:      newTTLCache[*slack.AuthTestResponse](ttl),
+		users:         newTTLCache[*slack.User](ttl),
+		channels:      newTTLCache[*slack.Channel](ttl),
+		conversations: newTTLCache[conversationsPage](ttl),
+	}
+}
+
+// lookup - the cached value for key, or the one fn looks up which is cached if there was no error
+func lookup[V any](c *cachingSlackClient, name string, cache *ttlCache[V], key string, fn func() (V, error)) (V, error) {
+	if v, ok := cache.get(key); ok {
+		c.metrics.CacheHit(name)
+		return v, nil
+	}
+	c.metrics.CacheMiss(name)
+	v, err := fn()
+	if err == nil {
+		cache.set(key, v)
+	}
+	return v, err
+}
+
+// channelInfoKey - the key of the info of a channel in the cache
+func channelInfoKey(channelID string, includeLocale bool) string {
+	return fmt.Sprintf("%s/%t", channelID, includeLocale)
+}
+
+// forgetChannel - forget the info of a channel
+func (c *cachingSlackClient) forgetChannel(channelID string) {
+	c.channels.delete(channelInfoKey(channelID, false))
+	c.channels.delete(channelInfoKey(channelID, true))
+}
+
+// channelChanged - forget the info of a channel, and which channels the bot is in
+func (c *cachingSlackClient) channelChanged(channelID string) {
+	c.forgetChannel(channelID)
+	c.conversations.clear()
+}
+
+func (c *cachingSlackClient) AuthTestContext(ctx context.Context) (*slack.AuthTestResponse, error) {
+	return lookup(c, cacheAuthTest, c.authTest, "", func() (*slack.AuthTestResponse, error) {
+		return c.SlackClient.AuthTestContext(ctx)
+	})
+}
+
+func (c *cachingSlackClient) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
+	return lookup(c, cacheUserInfo, c.users, user, func() (*slack.User, error) {
+		return c.SlackClient.GetUserInfoContext(ctx, user)
+	})
+}
+
+func (c *cachingSlackClient) GetConversationInfoContext(ctx context.Context, channelID string, includeLocale bool) (*slack.Channel, error) {
+	return lookup(c, cacheChannelInfo, c.channels, channelInfoKey(channelID, includeLocale), func() (*slack.Channel, error) {
+		return c.SlackClient.GetConversationInfoContext(ctx, channelID, includeLocale)
+	})
+}
+
+func (c *cachingSlackClient) GetConversationsForUserContext(ctx context.Context, params *slack.GetConversationsForUserParameters) ([]slack.Channel, string, error) {
+	key := fmt.Sprintf("%s/%s/%d/%t/%v", params.UserID, params.Cursor, params.Limit, params.ExcludeArchived, params.Types)
+	page, err := lookup(c, cacheConversations, c.conversations, key, func() (conversationsPage, error) {
+		channels, cursor, err := c.SlackClient.GetConversationsForUserContext(ctx, params)
+		return conversationsPage{channels: channels, cursor: cursor}, err
+	})
+	return page.channels, page.cursor, err
+}
+
+func (c *cachingSlackClient) CreateConversationContext(ctx context.Context, channelName string, isPrivate bool) (*slack.Channel, error) {
+	channel, err := c.SlackClient.CreateConversationContext(ctx, channelName, isPrivate)
+	if err == nil {
+		c.channelChanged(channel.ID)
+	}
+	return channel, err
+}
+
+func (c *cachingSlackClient) ArchiveConversationContext(ctx context.Context, channelID string) error {
+	// Forget the channel even if archiving failed, it may have been archived already
+	defer c.channelChanged(channelID)
+	return c.SlackClient.ArchiveConversationContext(ctx, channelID)
+}
+
+func (c *cachingSlackClient) UnArchiveConversationContext(ctx context.Context, channelID string) error {
+	defer c.channelChanged(channelID)
+	return c.SlackClient.UnArchiveConversationContext(ctx, channelID)
+}
+
+func (c *cachingSlackClient) SetPurposeOfConversationContext(ctx context.Context, channelID, purpose string) (*slack.Channel, error) {
+	defer c.forgetChannel(channelID)
+	return c.SlackClient.SetPurposeOfConversationContext(ctx, channelID, purpose)
+}
+
+func (c *cachingSlackClient) SetTopicOfConversationContext(ctx context.Context, channelID, topic string) (*slack.Channel, error) {
+	defer c.forgetChannel(channelID)
+	return c.SlackClient.SetTopicOfConversationContext(ctx, channelID, topic)
+}
+
+func (c *cachingSlackClient) InviteUsersToConversationContext(ctx context.Context, channelID string, users ...string) (*slack.Channel, error) {
+	// Forget the channel even if inviting failed, some of the users may have been invited
+	defer c.forgetChannel(channelID)
+	return c.SlackClient.InviteUsersToConversationContext(ctx, channelID, users...)
+}
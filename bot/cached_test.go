@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingClient - a dummyClient that counts the calls per method
+type countingClient struct {
+	*dummyClient
+	calls map[string]int
+}
+
+func (c *countingClient) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
+	c.calls["GetUserInfo"]++
+	return c.dummyClient.GetUserInfoContext(ctx, user)
+}
+
+func (c *countingClient) GetConversationInfoContext(ctx context.Context, channelID string, includeLocale bool) (*slack.Channel, error) {
+	c.calls["GetConversationInfo"]++
+	return c.dummyClient.GetConversationInfoContext(ctx, channelID, includeLocale)
+}
+
+func (c *countingClient) GetConversationsForUserContext(ctx context.Context, params *slack.GetConversationsForUserParameters) ([]slack.Channel, string, error) {
+	c.calls["GetConversationsForUser"]++
+	return c.dummyClient.GetConversationsForUserContext(ctx, params)
+}
+
+func TestCachingSlackClient(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics.MetricsRegisterer = reg
+	defer func() { metrics.MetricsRegisterer = prometheus.DefaultRegisterer }()
+
+	ctx := context.TODO()
+	channel := newTestChannel("C1", "general")
+	c := &countingClient{
+		dummyClient: &dummyClient{
+			User:         &slack.User{ID: "U1"},
+			Channel:      &channel,
+			ChannelPages: [][]slack.Channel{{channel}},
+		},
+		calls: map[string]int{},
+	}
+	cache := newCachingSlackClient(c, time.Minute, metrics.NewSlackCacheMetrics("test"))
+	now := time.Now()
+	cache.users.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		user, err := cache.GetUserInfoContext(ctx, "U1")
+		require.NoError(t, err)
+		assert.Equal(t, "U1", user.ID)
+	}
+	assert.Equal(t, 1, c.calls["GetUserInfo"])
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP test_slack_cache_lookups_total The number of lookups in the cache of Slack API responses by cache and result, hit or miss
+# TYPE test_slack_cache_lookups_total counter
+test_slack_cache_lookups_total{cache="user_info",result="hit"} 1
+test_slack_cache_lookups_total{cache="user_info",result="miss"} 1
+`), "test_slack_cache_lookups_total"))
+
+	// entries expire
+	now = now.Add(time.Minute)
+	_, err := cache.GetUserInfoContext(ctx, "U1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.calls["GetUserInfo"])
+
+	// failed lookups are not cached
+	c.err = errors.New("user_not_found")
+	_, err = cache.GetUserInfoContext(ctx, "U2")
+	assert.Error(t, err)
+	c.err = nil
+	_, err = cache.GetUserInfoContext(ctx, "U2")
+	require.NoError(t, err)
+	assert.Equal(t, 4, c.calls["GetUserInfo"])
+
+	// the channels of the bot and channel info are looked up again after the bot changes a channel
+	params := &slack.GetConversationsForUserParameters{UserID: "UBOT"}
+	for i := 0; i < 2; i++ {
+		_, _, err = cache.GetConversationsForUserContext(ctx, params)
+		require.NoError(t, err)
+		_, err = cache.GetConversationInfoContext(ctx, "C1", false)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, c.calls["GetConversationsForUser"])
+	assert.Equal(t, 1, c.calls["GetConversationInfo"])
+
+	_, err = cache.SetTopicOfConversationContext(ctx, "C1", "topic")
+	require.NoError(t, err)
+	_, _, err = cache.GetConversationsForUserContext(ctx, params)
+	require.NoError(t, err)
+	_, err = cache.GetConversationInfoContext(ctx, "C1", false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.calls["GetConversationsForUser"])
+	assert.Equal(t, 2, c.calls["GetConversationInfo"])
+
+	_, err = cache.InviteUsersToConversationContext(ctx, "C1", "U1")
+	require.NoError(t, err)
+	_, _, err = cache.GetConversationsForUserContext(ctx, params)
+	require.NoError(t, err)
+	_, err = cache.GetConversationInfoContext(ctx, "C1", false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.calls["GetConversationsForUser"])
+	assert.Equal(t, 3, c.calls["GetConversationInfo"])
+
+	require.NoError(t, cache.ArchiveConversationContext(ctx, "C1"))
+	_, _, err = cache.GetConversationsForUserContext(ctx, params)
+	require.NoError(t, err)
+	_, err = cache.GetConversationInfoContext(ctx, "C1", false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.calls["GetConversationsForUser"])
+	assert.Equal(t, 4, c.calls["GetConversationInfo"])
+}
+
+func TestTTLCacheSweep(t *testing.T) {
+	cache := newTTLCache[int](time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.set("a", 1)
+	now = now.Add(30 * time.Second)
+	cache.set("b", 2)
+	assert.Len(t, cache.entries, 2)
+
+	// expired values are removed when a value is added, even if they are never read again
+	now = now.Add(45 * time.Second)
+	cache.set("c", 3)
+	assert.Len(t, cache.entries, 2)
+	_, ok := cache.entries["a"]
+	assert.False(t, ok)
+	v, ok := cache.get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+}
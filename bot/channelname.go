@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+const (
+	// DefaultChannelNameTemplate - the default template of incident channel names
+	DefaultChannelNameTemplate = "inc_{{.Number}}_{{.Slug}}_{{.Date}}"
+	// DefaultChannelDateLayout - the default layout of the date in incident channel names
+	DefaultChannelDateLayout = "2Jan2006"
+)
+
+// Slack channel names can be at most 80 characters: https://api.slack.com/methods/conversations.create
+const maxChannelNameLength = 80
+
+var defaultChannelNamer = func() *ChannelNamer {
+	n, err := NewChannelNamer("", "", "")
+	if err != nil {
+		panic(err)
+	}
+	return n
+}()
+
+// ChannelNamer - creates and recognises incident channel names based on a template
+// with the placeholders {{.Number}}, {{.Slug}} and {{.Date}}
+type ChannelNamer struct {
+	tmpl       *template.Template
+	dateLayout string
+	loc        *time.Location
+	// regex - matches every name the template can create
+	regex *regexp.Regexp
+}
+
+type channelNameData struct {
+	Number int
+	Slug   string
+	Date   string
+}
+
+// NewChannelNamer - create a channel namer, empty arguments mean the defaults:
+// DefaultChannelNameTemplate, DefaultChannelDateLayout and the local time zone
+func NewChannelNamer(text, dateLayout, timezone string) (*ChannelNamer, error) {
+	if text == "" {
+		text = DefaultChannelNameTemplate
+	}
+	if dateLayout == "" {
+		dateLayout = DefaultChannelDateLayout
+	}
+	loc := time.Local
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("failed to load channel name time zone: %w", err)
+		}
+	}
+	tmpl, err := template.New("channelName").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse channel name template: %w", err)
+	}
+
+	// Only plain text and the placeholders are allowed, so that a regular
+	// expression matching all the created names can be derived from the template
+	pattern := &strings.Builder{}
+	pattern.WriteString("^")
+	unique := false
+	for _, node := range tmpl.Tree.Root.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+			pattern.WriteString(regexp.QuoteMeta(strings.ToLower(string(node.Text))))
+		case *parse.ActionNode:
+			switch strings.TrimSpace(node.Pipe.String()) {
+			case ".Number":
+				pattern.WriteString(`[0-9]+`)
+				unique = true
+			case ".Slug":
+				pattern.WriteString(`[a-z0-9_\-]+`)
+				unique = true
+			case ".Date":
+				pattern.WriteString(`[a-z0-9_\-]+`)
+			default:
+				return nil, fmt.Errorf("unknown placeholder %s in channel name template, use {{.Number}}, {{.Slug}} or {{.Date}}", node)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported %s in channel name template, use {{.Number}}, {{.Slug}} or {{.Date}}", node)
+		}
+	}
+	pattern.WriteString("$")
+	if !unique {
+		return nil, fmt.Errorf("channel name template %q must contain {{.Number}} or {{.Slug}}", text)
+	}
+
+	n := &ChannelNamer{
+		tmpl:       tmpl,
+		dateLayout: dateLayout,
+		loc:        loc,
+		regex:      regexp.MustCompile(pattern.String()),
+	}
+	example, err := n.Name(1, "example", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !channelNameRegex.MatchString(example) {
+		return nil, fmt.Errorf("channel name template %q with date layout %q creates invalid channel names like %q", text, dateLayout, example)
+	}
+	if n.MaxSlugLength() < 1 {
+		return nil, fmt.Errorf("channel name template %q with date layout %q leaves no room for a slug in channel names of at most %d characters", text, dateLayout, maxChannelNameLength)
+	}
+	return n, nil
+}
+
+// Name - the name of the channel for the incident with the given number and slug, declared at the given time
+func (n *ChannelNamer) Name(number int, slug string, at time.Time) (string, error) {
+	b := &strings.Builder{}
+	if err := n.tmpl.Execute(b, channelNameData{
+		Number: number,
+		Slug:   slug,
+		Date:   at.In(n.loc).Format(n.dateLayout),
+	}); err != nil {
+		return "", fmt.Errorf("failed to create channel name: %w", err)
+	}
+	return strings.ToLower(b.String()), nil
+}
+
+// Matches - whether the channel name could have been created by the namer
+func (n *ChannelNamer) Matches(name string) bool {
+	return n.regex.MatchString(name)
+}
+
+// Example - an example of a channel name, for hints
+func (n *ChannelNamer) Example() string {
+	name, err := n.Name(42, "example", time.Now())
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// MaxSlugLength - how long a slug can be without making channel names too long
+func (n *ChannelNamer) MaxSlugLength() int {
+	name, err := n.Name(0, "", time.Now())
+	if err != nil {
+		return 0
+	}
+	// Leave room for incident numbers with up to 6 digits
+	return maxChannelNameLength - len(name) - 5
+}
+
+// channelNamer - the configured channel namer, or the default one
+func (h *botHandler) channelNamer() *ChannelNamer {
+	if h.opts.ChannelNamer != nil {
+		return h.opts.ChannelNamer
+	}
+	return defaultChannelNamer
+}
@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelNamer(t *testing.T) {
+	at := time.Date(2022, 7, 1, 23, 30, 0, 0, time.UTC)
+
+	n, err := NewChannelNamer("", "", "UTC")
+	require.NoError(t, err)
+	name, err := n.Name(42, "db_down", at)
+	require.NoError(t, err)
+	assert.Equal(t, "inc_42_db_down_1jul2022", name)
+	assert.True(t, n.Matches(name))
+	assert.False(t, n.Matches("general"))
+	assert.False(t, n.Matches("inc_db_down_1jul2022"))
+	assert.Equal(t, 59, n.MaxSlugLength())
+
+	n, err = NewChannelNamer("incident-{{.Date}}-{{.Slug}}", "20060102", "Europe/Stockholm")
+	require.NoError(t, err)
+	name, err = n.Name(42, "db-down", at)
+	require.NoError(t, err)
+	assert.Equal(t, "incident-20220702-db-down", name)
+	assert.True(t, n.Matches(name))
+	assert.False(t, n.Matches("inc_42_db_down_1jul2022"))
+	assert.Equal(t, "incident-", n.Example()[:9])
+
+	_, err = NewChannelNamer("inc_{{.Name}}", "", "")
+	assert.Error(t, err)
+	_, err = NewChannelNamer("inc_{{if .Slug}}{{.Slug}}{{end}}", "", "")
+	assert.Error(t, err)
+	_, err = NewChannelNamer("inc_{{.Date}}", "", "")
+	assert.Error(t, err)
+	_, err = NewChannelNamer("inc {{.Slug}}", "", "")
+	assert.Error(t, err)
+	_, err = NewChannelNamer("", "2006/01/02", "")
+	assert.Error(t, err)
+	_, err = NewChannelNamer("", "", "Nowhere/Special")
+	assert.Error(t, err)
+	_, err = NewChannelNamer("incident_of_the_platform_team_in_the_production_environment_of_europe_{{.Number}}_{{.Slug}}_{{.Date}}", "", "")
+	assert.Error(t, err)
+}
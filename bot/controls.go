@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// controlsBlockID - the block with the buttons on the control message in an incident channel
+	controlsBlockID = "incident_controls"
+
+	actionControlUpdate   = "control_update"
+	actionControlEscalate = "control_escalate"
+	actionControlHandover = "control_handover"
+	actionControlResolve  = "control_resolve"
+)
+
+// controlText - the control message in an incident channel, the text is the fallback for notifications
+func controlText(inc *store.Incident) string {
+	return fmt.Sprintf(":joystick: Manage incident %s from here", incidentLabel(inc.Number, inc.ChannelID))
+}
+
+// controlBlocks - the control message in an incident channel with buttons that open the modals for the incident
+func (h *botHandler) controlBlocks(inc *store.Incident) []slack.Block {
+	button := func(actionID string, message *i18n.Message) *slack.ButtonBlockElement {
+		return slack.NewButtonBlockElement(actionID, inc.ChannelID,
+			slack.NewTextBlockObject(slack.PlainTextType, h.localizer().MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: message,
+			}), false, false))
+	}
+	resolve := button(actionControlResolve, &i18n.Message{
+		ID:    "Resolve",
+		Other: "Resolve"})
+	resolve.Style = slack.StylePrimary
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, controlText(inc), false, false), nil, nil),
+		slack.NewActionBlock(controlsBlockID,
+			button(actionControlUpdate, &i18n.Message{
+				ID:    "PostUpdate",
+				Other: "Post update"}),
+			button(actionControlEscalate, &i18n.Message{
+				ID:    "Escalate",
+				Other: "Escalate"}),
+			button(actionControlHandover, &i18n.Message{
+				ID:    "HandOver",
+				Other: "Hand over"}),
+			resolve,
+		),
+	}
+}
+
+// The actions of the permission policy that the control buttons lead to
+var controlActions = map[string]Action{
+	actionControlEscalate: ActionEscalate,
+	actionControlHandover: ActionHandover,
+	actionControlResolve:  ActionResolve,
+}
+
+// handleControlAction - open the modal for a button on the control message of an incident,
+// scoped to that incident
+func (h *botHandler) handleControlAction(ctx context.Context, payload *slack.InteractionCallback, action *slack.BlockAction) error {
+	log := zerolog.Ctx(ctx)
+	userID := payload.User.ID
+	channelID := action.Value
+	inc, err := h.incidents.Get(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
+		return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionPostEphemeral(userID),
+			slack.MsgOptionText(valUnknownIncident, false))
+	}
+	if err != nil {
+		return err
+	}
+	if inc.Status != store.StatusOpen {
+		return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionPostEphemeral(userID),
+			slack.MsgOptionText(valIncidentNotOpen, false))
+	}
+	if permission, restricted := controlActions[action.ActionID]; restricted {
+		if refusal, ok := h.authorize(ctx, userID, permission, inc); !ok {
+			return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionPostEphemeral(userID),
+				slack.MsgOptionText(refusal, false))
+		}
+	}
+
+	var modalVReq slack.ModalViewRequest
+	switch action.ActionID {
+	case actionControlUpdate:
+		modalVReq = h.updateIncidentModal(channelID)
+	case actionControlEscalate:
+		modalVReq, err = h.escalateIncidentModal(ctx, channelID, channelID)
+	case actionControlHandover:
+		modalVReq = h.handoverIncidentModal(channelID)
+	case actionControlResolve:
+		modalVReq, err = h.resolveIncidentModal(ctx, channelID)
+	default:
+		return fmt.Errorf("unknown control action %q", action.ActionID)
+	}
+	if err != nil {
+		var merr *modalError
+		if !errors.As(err, &merr) {
+			return err
+		}
+		if merr.err != nil {
+			log.Error().Err(merr.err).Str("action_id", action.ActionID).Msg("Could not build modal")
+		}
+		return h.sendMessage(ctx, payload.Channel.ID, slack.MsgOptionPostEphemeral(userID),
+			slack.MsgOptionText(merr.text, false))
+	}
+	scopeModal(&modalVReq, inc)
+
+	if _, err := h.slackClient.OpenViewContext(ctx, payload.TriggerID, modalVReq); err != nil {
+		return fmt.Errorf("failed to open view: %w", err)
+	}
+	return nil
+}
+
+// scopeModal - fix a modal to the given incident, replacing the incident channel picker with the
+// incident and keeping its channel in the private metadata
+func scopeModal(modalVReq *slack.ModalViewRequest, inc *store.Incident) {
+	for i, b := range modalVReq.Blocks.BlockSet {
+		if input, ok := b.(*slack.InputBlock); ok && input.BlockID == "incident_channel" {
+			modalVReq.Blocks.BlockSet[i] = slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+				"*Incident:* "+incidentLabel(inc.Number, inc.ChannelID), false, false), nil, nil)
+		}
+	}
+	modalVReq.PrivateMetadata = inc.ChannelID
+}
+
+// submittedIncidentChannel - the incident channel of a submitted modal, either the one the modal was
+// scoped to or the one chosen in it
+func submittedIncidentChannel(payload *slack.InteractionCallback) string {
+	if payload.View.PrivateMetadata != "" {
+		return payload.View.PrivateMetadata
+	}
+	return payload.View.State.Values["incident_channel"]["incident_channel"].SelectedConversation
+}
+
+// incidentField - the block to show an error about the incident of a submitted modal on, the incident
+// channel picker or the given block when the modal is scoped to an incident and has no picker
+func incidentField(payload *slack.InteractionCallback, scopedField string) string {
+	if payload.View.PrivateMetadata != "" {
+		return scopedField
+	}
+	return "incident_channel"
+}
@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestControlBlocks(t *testing.T) {
+	b := &botHandler{}
+	blocks := b.controlBlocks(&store.Incident{ChannelID: "C1", Number: 7})
+	require.Len(t, blocks, 2)
+	assert.Contains(t, blocks[0].(*slack.SectionBlock).Text.Text, "INC-7")
+	buttons := blocks[1].(*slack.ActionBlock).Elements.ElementSet
+	require.Len(t, buttons, 4)
+	for _, button := range buttons {
+		assert.Equal(t, "C1", button.(*slack.ButtonBlockElement).Value)
+	}
+	assert.Equal(t, "Hand over", buttons[2].(*slack.ButtonBlockElement).Text.Text)
+}
+
+func TestHandleControlAction(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C1", Number: 7, Status: store.StatusOpen, Commander: "U1"}))
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C2", Number: 8, Status: store.StatusResolved}))
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C3", Number: 9, Status: store.StatusOpen, Commander: "U2"}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+		admins:      &ugMembers{},
+		opts: Opts{
+			Localizer: i18n.NewLocalizer(i18n.NewBundle(language.English), language.English.String()),
+		},
+	}
+	click := func(channelID, actionID string) {
+		w := httptest.NewRecorder()
+		b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+			Type:      slack.InteractionTypeBlockActions,
+			User:      slack.User{ID: "U1"},
+			TriggerID: "trigger",
+			Channel:   slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: channelID}}},
+			ActionCallback: slack.ActionCallbacks{
+				BlockActions: []*slack.BlockAction{{BlockID: controlsBlockID, ActionID: actionID, Value: channelID}},
+			},
+		}))
+		assert.Equal(t, 200, w.Code)
+	}
+
+	// the modal has no incident channel to choose
+	click("C1", actionControlUpdate)
+	require.Len(t, c.views, 1)
+	assert.Equal(t, "update_incident", c.views[0].CallbackID)
+	assert.Equal(t, "C1", c.views[0].PrivateMetadata)
+	for _, block := range c.views[0].Blocks.BlockSet {
+		if input, ok := block.(*slack.InputBlock); ok {
+			assert.NotEqual(t, "incident_channel", input.BlockID)
+		}
+	}
+
+	click("C1", actionControlHandover)
+	require.Len(t, c.views, 2)
+	assert.Equal(t, "handover_incident", c.views[1].CallbackID)
+	assert.Equal(t, "C1", c.views[1].PrivateMetadata)
+
+	// resolved incidents can not be managed anymore
+	click("C2", actionControlUpdate)
+	assert.Len(t, c.views, 2)
+	require.Len(t, c.messages, 1)
+	assert.Equal(t, valIncidentNotOpen, c.messages[0].Get("text"))
+
+	// only the commander or an admin may hand over the roles
+	click("C3", actionControlHandover)
+	assert.Len(t, c.views, 2)
+	require.Len(t, c.messages, 2)
+	assert.Contains(t, c.messages[1].Get("text"), "You are not allowed to")
+}
+
+func TestSubmitScopedModal(t *testing.T) {
+	ctx := context.TODO()
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C1", Status: store.StatusResolved}))
+	b := &botHandler{
+		slackClient: &dummyClient{},
+		incidents:   incidents,
+	}
+
+	w := httptest.NewRecorder()
+	b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+		Type: slack.InteractionTypeViewSubmission,
+		View: slack.View{
+			CallbackID:      "update_incident",
+			PrivateMetadata: "C1",
+			State: &slack.ViewState{
+				Values: map[string]map[string]slack.BlockAction{
+					"incident_next_update": {"incident_next_update": {SelectedOption: slack.OptionBlockObject{Value: "30m"}}},
+				},
+			},
+		},
+	}))
+	// the incident is taken from the modal rather than from a chosen channel
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), valIncidentNotOpen)
+}
+
+func TestResolveIncidentModal(t *testing.T) {
+	b := &botHandler{
+		slackClient: &dummyClient{
+			AuthTestResponse: &slack.AuthTestResponse{UserID: "UBOT"},
+			Channel:          &slack.Channel{},
+			ChannelPages:     [][]slack.Channel{{newTestChannel("CB", "ops-incidents")}},
+		},
+		opts: Opts{
+			BroadcastChannelID: "CB",
+			Localizer:          i18n.NewLocalizer(i18n.NewBundle(language.English), language.English.String()),
+		},
+	}
+	modal, err := b.resolveIncidentModal(context.TODO(), "C1")
+	require.NoError(t, err)
+
+	// the channels of security incidents are private, so they must be choosable too
+	blocks, err := json.Marshal(modal.Blocks)
+	require.NoError(t, err)
+	assert.Contains(t, string(blocks), `"action_id":"incident_channel","initial_conversation":"C1","filter":{"include":["public","private"]}`)
+}
@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/slack-go/slack"
+)
+
+// Slack allows at most 100 options in a response to a block_suggestion payload
+const maxChannelSuggestions = 100
+
+// suggestionChannelsTTL - how long the channels of the bot are suggested in a modal before they are looked up again
+const suggestionChannelsTTL = time.Minute
+
+// botConversations - all the open channels the bot is a member of
+func (h *botHandler) botConversations(ctx context.Context) ([]slack.Channel, error) {
+	authTestResp, err := h.slackClient.AuthTestContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot user: %w", err)
+	}
+	channels := []slack.Channel{}
+	cursor := ""
+	for {
+		page, next, err := h.slackClient.GetConversationsForUserContext(ctx, &slack.GetConversationsForUserParameters{
+			UserID:          authTestResp.UserID,
+			Cursor:          cursor,
+			Limit:           200,
+			ExcludeArchived: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, page...)
+		if next == "" {
+			return channels, nil
+		}
+		cursor = next
+	}
+}
+
+// suggestionConversations - the channels of the bot to suggest in a modal, looked up once for the modal
+// instead of at every keystroke
+func (h *botHandler) suggestionConversations(ctx context.Context, viewID string) ([]slack.Channel, error) {
+	if h.suggestionChannels == nil || viewID == "" {
+		return h.botConversations(ctx)
+	}
+	if channels, ok := h.suggestionChannels.get(viewID); ok {
+		return channels, nil
+	}
+	channels, err := h.botConversations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h.suggestionChannels.set(viewID, channels)
+	return channels, nil
+}
+
+// isChannelMember - whether a user is a member of a channel the bot is a member of
+func (h *botHandler) isChannelMember(ctx context.Context, channelID, userID string) (bool, error) {
+	cursor := ""
+	for {
+		members, next, err := h.slackClient.GetUsersInConversationContext(ctx, &slack.GetUsersInConversationParameters{
+			ChannelID: channelID,
+			Cursor:    cursor,
+			Limit:     200,
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to get members of channel %s: %w", channelID, err)
+		}
+		for _, member := range members {
+			if member == userID {
+				return true, nil
+			}
+		}
+		if next == "" {
+			return false, nil
+		}
+		cursor = next
+	}
+}
+
+// broadcastChannelBlock - input block for choosing the broadcast channel among the channels of the bot,
+// searched via block_suggestion payloads, with the configured broadcast channel chosen
+func (h *botHandler) broadcastChannelBlock(ctx context.Context) (*slack.InputBlock, error) {
+	channels, err := h.botConversations(ctx)
+	if err != nil {
+		return nil, &modalError{text: fmt.Sprintf("Failed to get conversations for bot: %s", err), err: err}
+	}
+	if len(channels) == 0 {
+		return nil, &modalError{text: "Bot must be added to a channel for broadcasting messages"}
+	}
+	botInBroadcastChannel := false
+	for i := range channels {
+		if channels[i].ID == h.opts.BroadcastChannelID {
+			botInBroadcastChannel = true
+		}
+	}
+	if !botInBroadcastChannel {
+		return nil, &modalError{text: fmt.Sprintf("The bot is not part of the configured broadcast channel <#%s>, invite it there first", h.opts.BroadcastChannelID)}
+	}
+	broadcastChannel, err := h.slackClient.GetConversationInfoContext(ctx, h.opts.BroadcastChannelID, false)
+	if err != nil {
+		return nil, &modalError{text: fmt.Sprintf("Failed to get the configured broadcast channel <#%s>: %s", h.opts.BroadcastChannelID, err), err: err}
+	}
+	if broadcastChannel.IsArchived {
+		return nil, &modalError{text: fmt.Sprintf("The configured broadcast channel <#%s> is archived, update the configuration to use an open broadcast channel", h.opts.BroadcastChannelID)}
+	}
+
+	broadcastChLabel := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "BroadcastChannel",
+				Other: "Broadcast channel"},
+		}), false, false)
+	broadcastChOption := slack.NewOptionsSelectBlockElement(slack.OptTypeExternal, nil, "broadcast_channel")
+	// Show the channels as soon as the select is opened
+	minQueryLength := 0
+	broadcastChOption.MinQueryLength = &minQueryLength
+	broadcastChOption.InitialOption = channelOption(broadcastChannel)
+	broadcastChHint := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "BroadcastChannelHint",
+				Other: "The channels listed are the ones that the bot has been added to as a user"},
+		}), false, false)
+	return slack.NewInputBlock("broadcast_channel", broadcastChLabel, broadcastChHint, broadcastChOption), nil
+}
+
+// channelOption - an option for a channel, labelled with its name since options can not mention channels
+func channelOption(channel *slack.Channel) *slack.OptionBlockObject {
+	return slack.NewOptionBlockObject(channel.ID,
+		slack.NewTextBlockObject(slack.PlainTextType, "#"+channel.Name, false, false), nil)
+}
+
+// broadcastChannelSuggestions - respond to a block_suggestion payload of the broadcast channel select
+// with the channels of the bot whose name contains the query
+func (h *botHandler) broadcastChannelSuggestions(ctx context.Context, payload *slack.InteractionCallback, w http.ResponseWriter) error {
+	channels, err := h.suggestionConversations(ctx, payload.View.ID)
+	if err != nil {
+		return err
+	}
+	query := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(payload.Value), "#"))
+	matches := []slack.Channel{}
+	for _, c := range channels {
+		if strings.Contains(strings.ToLower(c.Name), query) {
+			matches = append(matches, c)
+		}
+	}
+	// Channels starting with the query first, then by name
+	sort.SliceStable(matches, func(i, j int) bool {
+		pi, pj := strings.HasPrefix(strings.ToLower(matches[i].Name), query), strings.HasPrefix(strings.ToLower(matches[j].Name), query)
+		if pi != pj {
+			return pi
+		}
+		return matches[i].Name < matches[j].Name
+	})
+	if len(matches) > maxChannelSuggestions {
+		matches = matches[:maxChannelSuggestions]
+	}
+	options := make([]*slack.OptionBlockObject, 0, len(matches))
+	for i := range matches {
+		options = append(options, channelOption(&matches[i]))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(&slack.OptionsResponse{Options: options})
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func newTestChannel(id, name string) slack.Channel {
+	return slack.Channel{GroupConversation: slack.GroupConversation{Name: name, Conversation: slack.Conversation{ID: id}}}
+}
+
+func TestBroadcastChannelBlock(t *testing.T) {
+	ctx := context.TODO()
+	broadcast := newTestChannel("B1", "announcements")
+	c := &dummyClient{
+		AuthTestResponse: &slack.AuthTestResponse{UserID: "UBOT"},
+		Channel:          &broadcast,
+		ChannelPages: [][]slack.Channel{
+			{newTestChannel("C1", "general")},
+			{newTestChannel("C2", "random"), broadcast},
+		},
+	}
+	b := &botHandler{
+		slackClient: c,
+		opts: Opts{
+			BroadcastChannelID: "B1",
+			Localizer:          i18n.NewLocalizer(i18n.NewBundle(language.English), language.English.String()),
+		},
+	}
+
+	// the broadcast channel is found on a later page
+	block, err := b.broadcastChannelBlock(ctx)
+	require.NoError(t, err)
+	element := block.Element.(*slack.SelectBlockElement)
+	assert.Equal(t, slack.OptTypeExternal, element.Type)
+	assert.Equal(t, "B1", element.InitialOption.Value)
+	assert.Equal(t, "#announcements", element.InitialOption.Text.Text)
+
+	b.opts.BroadcastChannelID = "B2"
+	_, err = b.broadcastChannelBlock(ctx)
+	var merr *modalError
+	require.True(t, errors.As(err, &merr))
+	assert.Contains(t, merr.text, "The bot is not part of the configured broadcast channel <#B2>")
+}
+
+func TestBroadcastChannelSuggestions(t *testing.T) {
+	c := &dummyClient{
+		AuthTestResponse: &slack.AuthTestResponse{UserID: "UBOT"},
+		ChannelPages: [][]slack.Channel{
+			{newTestChannel("C1", "general"), newTestChannel("C2", "ops-incidents")},
+			{newTestChannel("C3", "incidents"), newTestChannel("C4", "random")},
+		},
+	}
+	b := &botHandler{
+		slackClient:        c,
+		suggestionChannels: newTTLCache[[]slack.Channel](time.Minute),
+	}
+	suggest := func(viewID, value string) []*slack.OptionBlockObject {
+		w := httptest.NewRecorder()
+		b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+			Type:     slack.InteractionTypeBlockSuggestion,
+			ActionID: "broadcast_channel",
+			View:     slack.View{ID: viewID},
+			Value:    value,
+		}))
+		assert.Equal(t, 200, w.Code)
+		resp := slack.OptionsResponse{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Options
+	}
+
+	options := suggest("V1", "#Incid")
+	require.Len(t, options, 2)
+	assert.Equal(t, "C3", options[0].Value)
+	assert.Equal(t, "#incidents", options[0].Text.Text)
+	assert.Equal(t, "C2", options[1].Value)
+
+	// the channels are looked up once per modal
+	c.ChannelPages = [][]slack.Channel{{newTestChannel("C5", "incidents-eu")}}
+	assert.Len(t, suggest("V1", "incidents"), 2)
+	options = suggest("V2", "incidents")
+	require.Len(t, options, 1)
+	assert.Equal(t, "C5", options[0].Value)
+}
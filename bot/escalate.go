@@ -0,0 +1,260 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/internal/wrappedcontext"
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+const valNothingChanged = "Choose a different severity or impact than the current one"
+
+// cmdEscalateIncident - handler for changing the severity and impact of an incident
+func (h *botHandler) cmdEscalateIncident(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, incident string) error {
+	initialChannelID, err := h.incidentArgChannel(ctx, incident)
+	if errors.Is(err, store.ErrNotFound) {
+		return h.errorResponse(ctx, w, cmd, h.unknownIncidentText(incident), nil)
+	}
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to get incident: %s", err), err)
+	}
+
+	// Preselect the current values of the given incident, or of the incident the command is run in
+	currentChannelID := initialChannelID
+	if currentChannelID == "" {
+		currentChannelID = cmd.ChannelID
+	}
+	modalVReq, err := h.escalateIncidentModal(ctx, initialChannelID, currentChannelID)
+	if err != nil {
+		return h.modalErrorResponse(ctx, w, cmd, err)
+	}
+
+	_, err = h.slackClient.OpenViewContext(ctx, cmd.TriggerID, modalVReq)
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Error opening view: %s", err), err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// escalateIncidentModal - the modal for changing the severity and impact of an incident, with the given
+// incident channel chosen if any, and the current values of the incident in currentChannelID preselected
+func (h *botHandler) escalateIncidentModal(ctx context.Context, initialChannelID, currentChannelID string) (slack.ModalViewRequest, error) {
+	titleText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "EscalateAnIncident",
+				Other: "Change incident severity"},
+		}), false, false)
+	closeText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Cancel",
+				Other: "Cancel"},
+		}), false, false)
+	submitText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "ChangeSeverity",
+				Other: "Change severity"},
+		}), false, false)
+
+	contextText := slack.NewTextBlockObject(slack.MarkdownType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "EscalateIncidentDescription",
+				Other: "This will change the severity and impact of an incident, update the incident channel, and notify about the change in the broadcast channel"},
+		}), false, false)
+	contextBlock := slack.NewContextBlock("context", contextText)
+
+	current, err := h.incidents.Get(ctx, currentChannelID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return slack.ModalViewRequest{}, &modalError{text: fmt.Sprintf("Failed to get incident: %s", err), err: err}
+	}
+
+	severityTxt := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Severity",
+				Other: "Severity"},
+		}), false, false)
+	var severityLevels []string
+	if err := json.Unmarshal([]byte(h.opts.IncidentSeverityLevels), &severityLevels); err != nil {
+		return slack.ModalViewRequest{}, &modalError{text: "Failed to unmarshal incident severity levels", err: err}
+	}
+	severityOptions := createOptionBlockObjects(severityLevels, "")
+	severityOptionsBlock := slack.NewRadioButtonsBlockElement("incident_severity_level", severityOptions...)
+	severityBlock := slack.NewInputBlock("incident_severity_level", severityTxt, nil, severityOptionsBlock)
+
+	impactTxt := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Impact",
+				Other: "Impact"},
+		}), false, false)
+	var impactLevels []string
+	if err := json.Unmarshal([]byte(h.opts.IncidentImpactLevels), &impactLevels); err != nil {
+		return slack.ModalViewRequest{}, &modalError{text: "Failed to unmarshal incident impact levels", err: err}
+	}
+	impactOptions := createOptionBlockObjects(impactLevels, "")
+	impactOptionsBlock := slack.NewRadioButtonsBlockElement("incident_impact_level", impactOptions...)
+	impactBlock := slack.NewInputBlock("incident_impact_level", impactTxt, nil, impactOptionsBlock)
+
+	if current != nil {
+		for _, o := range severityOptions {
+			if o.Value == current.Severity {
+				severityOptionsBlock.InitialOption = o
+			}
+		}
+		for _, o := range impactOptions {
+			if o.Value == current.Impact {
+				impactOptionsBlock.InitialOption = o
+			}
+		}
+	}
+
+	reasonLabel := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Reason",
+				Other: "Reason"},
+		}), false, false)
+	reasonElement := slack.NewPlainTextInputBlockElement(reasonLabel, "reason")
+	reasonElement.MaxLength = 200
+	reasonElement.Multiline = true
+	reasonBlock := slack.NewInputBlock("reason", reasonLabel, nil, reasonElement)
+
+	blocks := slack.Blocks{
+		BlockSet: []slack.Block{
+			contextBlock,
+			h.incidentChannelBlock(initialChannelID),
+			severityBlock,
+			impactBlock,
+			reasonBlock,
+		},
+	}
+
+	var modalVReq slack.ModalViewRequest
+	modalVReq.Type = slack.ViewType("modal")
+	modalVReq.Title = titleText
+	modalVReq.Close = closeText
+	modalVReq.Submit = submitText
+	modalVReq.Blocks = blocks
+	modalVReq.ClearOnClose = true
+	modalVReq.CallbackID = "escalate_incident"
+	return modalVReq, nil
+}
+
+type escalateParams struct {
+	// Incident channel ID
+	incidentChannel string
+	// The new severity level
+	incidentSeverityLevel string
+	// The new impact level
+	incidentImpactLevel string
+	// Why the severity or impact changed
+	reason string
+	// Who changed the severity or impact
+	incidentEscalator string
+}
+
+// escalateIncident - handler for changing the severity and impact of an incident
+func (h *botHandler) escalateIncident(ctx context.Context, payload *slack.InteractionCallback, w http.ResponseWriter) error {
+	incidentChannelID := submittedIncidentChannel(payload)
+	field := incidentField(payload, "incident_severity_level")
+	if err := h.validateOpenIncident(ctx, field, incidentChannelID); err != nil {
+		var verr *validationError
+		if errors.As(err, &verr) {
+			return postErrorResponse(ctx, verr.errors, w)
+		}
+		return err
+	}
+	escalateParams := &escalateParams{
+		incidentChannel:       incidentChannelID,
+		incidentSeverityLevel: payload.View.State.Values["incident_severity_level"]["incident_severity_level"].SelectedOption.Value,
+		incidentImpactLevel:   payload.View.State.Values["incident_impact_level"]["incident_impact_level"].SelectedOption.Value,
+		reason:                payload.View.State.Values["reason"]["reason"].Value,
+		incidentEscalator:     payload.User.ID,
+	}
+	inc, err := h.incidents.Get(ctx, incidentChannelID)
+	if err != nil {
+		return err
+	}
+	if refusal, ok := h.authorize(ctx, payload.User.ID, ActionEscalate, inc); !ok {
+		return postErrorResponse(ctx, map[string]string{field: refusal}, w)
+	}
+	if inc.Severity == escalateParams.incidentSeverityLevel && inc.Impact == escalateParams.incidentImpactLevel {
+		return postErrorResponse(ctx, map[string]string{
+			"incident_severity_level": valNothingChanged,
+		}, w)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	// Do the rest via goroutine, which outlives the request and can wait for Slack to recover
+	ctx = waitForSlack(wrappedcontext.WrapContextValues(context.Background(), ctx))
+	go h.doEscalateTasks(ctx, escalateParams)
+
+	return nil
+}
+
+// doEscalateTasks - record a change of severity and impact and announce it
+func (h *botHandler) doEscalateTasks(ctx context.Context, params *escalateParams) {
+	log := zerolog.Ctx(ctx)
+	var change store.SeverityChange
+	inc, err := h.incidents.Update(ctx, params.incidentChannel, func(inc *store.Incident) error {
+		change = store.SeverityChange{
+			At:           time.Now(),
+			By:           params.incidentEscalator,
+			FromSeverity: inc.Severity,
+			ToSeverity:   params.incidentSeverityLevel,
+			FromImpact:   inc.Impact,
+			ToImpact:     params.incidentImpactLevel,
+			Reason:       params.reason,
+		}
+		inc.SeverityChanges = append(inc.SeverityChanges, change)
+		inc.Severity = params.incidentSeverityLevel
+		inc.Impact = params.incidentImpactLevel
+		inc.AddEvent(change.At, change.By, store.EventSeverityChanged,
+			fmt.Sprintf("Changed severity %s → %s and impact %s → %s: %s",
+				change.FromSeverity, change.ToSeverity, change.FromImpact, change.ToImpact, change.Reason))
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not record severity change")
+		return
+	}
+
+	if err := h.setIncidentOverview(ctx, inc); err != nil {
+		log.Error().Err(err).Msg("Could not update incident channel overview")
+		if sendErr := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionPostEphemeral(params.incidentEscalator),
+			slack.MsgOptionText(fmt.Sprintf("Failed to update incident channel: %s", err.Error()), false)); sendErr != nil {
+			log.Error().Err(sendErr).Msg("Could not send failure message")
+		}
+	}
+
+	text := fmt.Sprintf(":rotating_light: The severity of %s has been changed by <@%s>\n"+
+		"*Severity:* %s → %s\n"+
+		"*Impact:* %s → %s\n"+
+		"*Reason:* %s",
+		incidentLabel(inc.Number, inc.ChannelID), change.By,
+		change.FromSeverity, change.ToSeverity,
+		change.FromImpact, change.ToImpact,
+		change.Reason)
+	if err := h.sendMessage(ctx, inc.BroadcastChannelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Msg("Could not send severity change to broadcast channel")
+	}
+	if err := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Msg("Could not send severity change to incident channel")
+	}
+	h.notifySubscribers(ctx, inc, text)
+}
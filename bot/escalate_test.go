@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalateIncident(t *testing.T) {
+	ctx := context.TODO()
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID: "C1",
+		Status:    store.StatusOpen,
+		Severity:  "low",
+		Impact:    "low",
+		Commander: "U1",
+	}))
+	b := &botHandler{
+		slackClient: &dummyClient{},
+		incidents:   incidents,
+		admins:      &ugMembers{},
+	}
+
+	// choosing the current values is refused
+	w := httptest.NewRecorder()
+	b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+		Type: slack.InteractionTypeViewSubmission,
+		User: slack.User{ID: "U1"},
+		View: slack.View{
+			CallbackID: "escalate_incident",
+			State: &slack.ViewState{
+				Values: map[string]map[string]slack.BlockAction{
+					"incident_channel":        {"incident_channel": {SelectedConversation: "C1"}},
+					"incident_severity_level": {"incident_severity_level": {SelectedOption: slack.OptionBlockObject{Value: "low"}}},
+					"incident_impact_level":   {"incident_impact_level": {SelectedOption: slack.OptionBlockObject{Value: "low"}}},
+				},
+			},
+		},
+	}))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), valNothingChanged)
+}
+
+func TestDoEscalateTasks(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:          "C1",
+		BroadcastChannelID: "B1",
+		Status:             store.StatusOpen,
+		Severity:           "low",
+		Impact:             "medium",
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+	}
+
+	b.doEscalateTasks(ctx, &escalateParams{
+		incidentChannel:       "C1",
+		incidentSeverityLevel: "high",
+		incidentImpactLevel:   "medium",
+		reason:                "Customers are affected",
+		incidentEscalator:     "U1",
+	})
+
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, "high", inc.Severity)
+	require.Len(t, inc.SeverityChanges, 1)
+	assert.Equal(t, "low", inc.SeverityChanges[0].FromSeverity)
+	assert.Equal(t, "high", inc.SeverityChanges[0].ToSeverity)
+	assert.Equal(t, "U1", inc.SeverityChanges[0].By)
+
+	require.Len(t, c.messages, 2)
+	assert.Equal(t, "B1", c.messages[0].Get("channel"))
+	assert.Contains(t, c.messages[0].Get("text"), "low → high")
+	assert.Contains(t, c.messages[0].Get("text"), "medium → medium")
+}
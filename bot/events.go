@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/karl-johan-grahn/devopsbot/internal/wrappedcontext"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Event types without a constant in slackevents
+const (
+	eventSubteamMembersChanged = "subteam_members_changed"
+)
+
+// eventDispatcher - runs the handlers registered for the type of an event callback
+type eventDispatcher struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(ctx context.Context, data interface{})
+}
+
+func newEventDispatcher() *eventDispatcher {
+	return &eventDispatcher{handlers: map[string][]func(ctx context.Context, data interface{}){}}
+}
+
+// onEvent - register a handler for the events of a type, E is the type slackevents parses them into,
+// like slackevents.AppMentionEvent for slackevents.AppMention
+func onEvent[E any](d *eventDispatcher, eventType string, handler func(ctx context.Context, ev *E)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[eventType] = append(d.handlers[eventType], func(ctx context.Context, data interface{}) {
+		ev, ok := data.(*E)
+		if !ok {
+			zerolog.Ctx(ctx).Error().Str("event_type", eventType).Msgf("Event handler expects %T but got %T", ev, data)
+			return
+		}
+		handler(ctx, ev)
+	})
+}
+
+// dispatch - run the handlers of an event callback, each in its own goroutine, returning how many were started
+func (d *eventDispatcher) dispatch(ctx context.Context, event slackevents.EventsAPIInnerEvent) int {
+	d.mu.RLock()
+	handlers := d.handlers[event.Type]
+	d.mu.RUnlock()
+
+	log := zerolog.Ctx(ctx).With().Str("event_type", event.Type).Logger()
+	ctx = log.WithContext(ctx)
+	if len(handlers) == 0 {
+		log.Debug().Msg("Ignoring event")
+	}
+	for _, handler := range handlers {
+		go func(handler func(ctx context.Context, data interface{})) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error().Err(fmt.Errorf("%v", r)).Msg("Event handler panicked")
+				}
+			}()
+			handler(ctx, event.Data)
+		}(handler)
+	}
+	return len(handlers)
+}
+
+// registerEventHandlers - register the handlers of the bot for event callbacks
+func (h *botHandler) registerEventHandlers() {
+	onEvent(h.events, eventSubteamMembersChanged, h.adminGroupChanged)
+}
+
+// handleEvents - handler for the Slack Events API, event callbacks are acknowledged before they are handled
+func (h *botHandler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := zerolog.Ctx(ctx)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read event")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// The request is already verified with the signing secret
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to parse event")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		verification, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(verification.Challenge))
+	case slackevents.CallbackEvent:
+		// Slack sends the event again if it is not acknowledged within three seconds
+		w.WriteHeader(http.StatusOK)
+
+		ctx = wrappedcontext.WrapContextValues(context.Background(), ctx)
+		h.events.dispatch(ctx, event.InnerEvent)
+	default:
+		log.Warn().Str("type", event.Type).Msg("Unknown event")
+		w.WriteHeader(http.StatusOK)
+	}
+}
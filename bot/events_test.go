@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventDispatcher(t *testing.T) {
+	ctx := context.TODO()
+	d := newEventDispatcher()
+	mentions := make(chan *slackevents.AppMentionEvent, 2)
+	onEvent(d, string(slackevents.AppMention), func(ctx context.Context, ev *slackevents.AppMentionEvent) {
+		mentions <- ev
+	})
+	onEvent(d, string(slackevents.AppMention), func(ctx context.Context, ev *slackevents.AppMentionEvent) {
+		mentions <- ev
+	})
+	// a handler of the wrong type is not run, and a panicking handler does not take the bot down
+	onEvent(d, string(slackevents.ReactionAdded), func(ctx context.Context, ev *slackevents.AppMentionEvent) {
+		t.Error("handler of the wrong type was run")
+	})
+	onEvent(d, string(slackevents.ChannelArchive), func(ctx context.Context, ev *slackevents.ChannelArchiveEvent) {
+		panic("oops")
+	})
+
+	assert.Equal(t, 2, d.dispatch(ctx, slackevents.EventsAPIInnerEvent{
+		Type: string(slackevents.AppMention),
+		Data: &slackevents.AppMentionEvent{User: "U1", Text: "<@UBOT> help"},
+	}))
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-mentions:
+			assert.Equal(t, "U1", ev.User)
+		case <-time.After(time.Second):
+			t.Fatal("app_mention was not handled")
+		}
+	}
+
+	assert.Equal(t, 1, d.dispatch(ctx, slackevents.EventsAPIInnerEvent{
+		Type: string(slackevents.ReactionAdded),
+		Data: &slackevents.ReactionAddedEvent{User: "U1"},
+	}))
+	assert.Equal(t, 1, d.dispatch(ctx, slackevents.EventsAPIInnerEvent{
+		Type: string(slackevents.ChannelArchive),
+		Data: &slackevents.ChannelArchiveEvent{Channel: "C1"},
+	}))
+	assert.Equal(t, 0, d.dispatch(ctx, slackevents.EventsAPIInnerEvent{
+		Type: string(slackevents.MemberJoinedChannel),
+		Data: &slackevents.MemberJoinedChannelEvent{Channel: "C1"},
+	}))
+}
+
+func TestHandleEvents(t *testing.T) {
+	changes := make(chan *slack.SubteamMembersChangedEvent, 1)
+	b := &botHandler{events: newEventDispatcher()}
+	onEvent(b.events, eventSubteamMembersChanged, func(ctx context.Context, ev *slack.SubteamMembersChangedEvent) {
+		changes <- ev
+	})
+
+	w := httptest.NewRecorder()
+	b.handleEvents(w, httptest.NewRequest("POST", "/events",
+		strings.NewReader(`{"type": "url_verification", "token": "t", "challenge": "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`)))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P", w.Body.String())
+
+	w = httptest.NewRecorder()
+	b.handleEvents(w, httptest.NewRequest("POST", "/events",
+		strings.NewReader(`{"type": "event_callback", "token": "t", "event": {"type": "subteam_members_changed", "subteam_id": "S1", "removed_users": ["U1"]}}`)))
+	assert.Equal(t, 200, w.Code)
+	select {
+	case ev := <-changes:
+		assert.Equal(t, "S1", ev.SubteamID)
+		assert.Equal(t, []string{"U1"}, ev.RemovedUsers)
+	case <-time.After(time.Second):
+		t.Fatal("subteam_members_changed was not handled")
+	}
+
+	w = httptest.NewRecorder()
+	b.handleEvents(w, httptest.NewRequest("POST", "/events",
+		strings.NewReader(`{"type": "event_callback", "token": "t", "event": {"type": "reaction_added", "user": "U1", "reaction": "eyes"}}`)))
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	b.handleEvents(w, httptest.NewRequest("POST", "/events", strings.NewReader(`{`)))
+	assert.Equal(t, 400, w.Code)
+}
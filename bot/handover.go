@@ -0,0 +1,293 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/internal/wrappedcontext"
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+const (
+	roleCommander = "commander"
+	roleResponder = "responder"
+
+	valSameRoleHolder         = "This person already has the role"
+	valNotInSecurityChannel   = "Only members of the channel of a security incident may hand over its roles"
+	valNewHolderNotInSecurity = "The roles of a security incident are only handed over to members of its channel, invite this person to the channel first"
+)
+
+// cmdHandoverIncident - handler for handing over the commander or responder role of an incident
+func (h *botHandler) cmdHandoverIncident(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, incident string) error {
+	initialChannelID, err := h.incidentArgChannel(ctx, incident)
+	if errors.Is(err, store.ErrNotFound) {
+		return h.errorResponse(ctx, w, cmd, h.unknownIncidentText(incident), nil)
+	}
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to get incident: %s", err), err)
+	}
+
+	_, err = h.slackClient.OpenViewContext(ctx, cmd.TriggerID, h.handoverIncidentModal(initialChannelID))
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Error opening view: %s", err), err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// handoverIncidentModal - the modal for handing over a role, with the given incident channel chosen if any
+func (h *botHandler) handoverIncidentModal(initialChannelID string) slack.ModalViewRequest {
+	titleText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "HandOverARole",
+				Other: "Hand over a role"},
+		}), false, false)
+	closeText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Cancel",
+				Other: "Cancel"},
+		}), false, false)
+	submitText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "HandOver",
+				Other: "Hand over"},
+		}), false, false)
+
+	contextText := slack.NewTextBlockObject(slack.MarkdownType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "HandoverDescription",
+				Other: "This will hand over the commander or responder role of an incident to someone else, invite them to the incident channel, and notify about the handover in the broadcast channel"},
+		}), false, false)
+	contextBlock := slack.NewContextBlock("context", contextText)
+
+	roleTxt := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Role",
+				Other: "Role"},
+		}), false, false)
+	commanderText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Commander",
+				Other: "Commander"},
+		}), false, false)
+	responderText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Responder",
+				Other: "Responder"},
+		}), false, false)
+	commanderOption := slack.NewOptionBlockObject(roleCommander, commanderText, nil)
+	roleOptionsBlock := slack.NewRadioButtonsBlockElement("incident_role",
+		commanderOption,
+		slack.NewOptionBlockObject(roleResponder, responderText, nil))
+	roleOptionsBlock.InitialOption = commanderOption
+	roleBlock := slack.NewInputBlock("incident_role", roleTxt, nil, roleOptionsBlock)
+
+	newUserText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "HandOverTo",
+				Other: "Hand over to"},
+		}), false, false)
+	newUserOption := slack.NewOptionsSelectBlockElement(slack.OptTypeUser, newUserText, "incident_new_role_holder")
+	newUserBlock := slack.NewInputBlock("incident_new_role_holder", newUserText, nil, newUserOption)
+
+	noteLabel := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "HandoverNote",
+				Other: "Handover note"},
+		}), false, false)
+	noteElement := slack.NewPlainTextInputBlockElement(noteLabel, "handover_note")
+	noteElement.MaxLength = 500
+	noteElement.Multiline = true
+	noteBlock := slack.NewInputBlock("handover_note", noteLabel, nil, noteElement)
+	noteBlock.Optional = true
+
+	blocks := slack.Blocks{
+		BlockSet: []slack.Block{
+			contextBlock,
+			h.incidentChannelBlock(initialChannelID),
+			roleBlock,
+			newUserBlock,
+			noteBlock,
+		},
+	}
+
+	var modalVReq slack.ModalViewRequest
+	modalVReq.Type = slack.ViewType("modal")
+	modalVReq.Title = titleText
+	modalVReq.Close = closeText
+	modalVReq.Submit = submitText
+	modalVReq.Blocks = blocks
+	modalVReq.ClearOnClose = true
+	modalVReq.CallbackID = "handover_incident"
+	return modalVReq
+}
+
+type handoverParams struct {
+	// Incident channel ID
+	incidentChannel string
+	// The role that is handed over, roleCommander or roleResponder
+	role string
+	// Who takes over the role
+	newRoleHolder string
+	// Anything the new role holder should know
+	note string
+	// Who handed over the role
+	incidentHandoverBy string
+}
+
+// handoverIncident - handler for handing over a role of an incident
+func (h *botHandler) handoverIncident(ctx context.Context, payload *slack.InteractionCallback, w http.ResponseWriter) error {
+	incidentChannelID := submittedIncidentChannel(payload)
+	if err := h.validateOpenIncident(ctx, incidentField(payload, "incident_role"), incidentChannelID); err != nil {
+		var verr *validationError
+		if errors.As(err, &verr) {
+			return postErrorResponse(ctx, verr.errors, w)
+		}
+		return err
+	}
+	handoverParams := &handoverParams{
+		incidentChannel:    incidentChannelID,
+		role:               payload.View.State.Values["incident_role"]["incident_role"].SelectedOption.Value,
+		newRoleHolder:      payload.View.State.Values["incident_new_role_holder"]["incident_new_role_holder"].SelectedUser,
+		note:               payload.View.State.Values["handover_note"]["handover_note"].Value,
+		incidentHandoverBy: payload.User.ID,
+	}
+	inc, err := h.incidents.Get(ctx, incidentChannelID)
+	if err != nil {
+		return err
+	}
+	if verrs, err := h.authorizeHandover(ctx, payload, inc, handoverParams.newRoleHolder); err != nil {
+		return err
+	} else if len(verrs) > 0 {
+		return postErrorResponse(ctx, verrs, w)
+	}
+	if roleHolder(inc, handoverParams.role) == handoverParams.newRoleHolder {
+		return postErrorResponse(ctx, map[string]string{
+			"incident_new_role_holder": valSameRoleHolder,
+		}, w)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	// Do the rest via goroutine, which outlives the request and can wait for Slack to recover
+	ctx = waitForSlack(wrappedcontext.WrapContextValues(context.Background(), ctx))
+	go h.doHandoverTasks(ctx, handoverParams)
+
+	return nil
+}
+
+// authorizeHandover - check that the submitter may hand over the roles of the incident, and for security
+// incidents that the bot does not invite anyone into their private channel, returning the errors to show
+func (h *botHandler) authorizeHandover(ctx context.Context, payload *slack.InteractionCallback, inc *store.Incident, newRoleHolder string) (map[string]string, error) {
+	field := incidentField(payload, "incident_role")
+	userID := payload.User.ID
+	if refusal, ok := h.authorize(ctx, userID, ActionHandover, inc); !ok {
+		return map[string]string{field: refusal}, nil
+	}
+	if !inc.SecurityRelated {
+		return nil, nil
+	}
+	member, err := h.isChannelMember(ctx, inc.ChannelID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !member {
+		return map[string]string{field: valNotInSecurityChannel}, nil
+	}
+	member, err = h.isChannelMember(ctx, inc.ChannelID, newRoleHolder)
+	if err != nil {
+		return nil, err
+	}
+	if !member {
+		return map[string]string{"incident_new_role_holder": valNewHolderNotInSecurity}, nil
+	}
+	return nil, nil
+}
+
+// doHandoverTasks - record a handover, bring the new role holder in, and announce it
+func (h *botHandler) doHandoverTasks(ctx context.Context, params *handoverParams) {
+	log := zerolog.Ctx(ctx)
+	const sendError = "Could not send failure message"
+	var change store.RoleChange
+	inc, err := h.incidents.Update(ctx, params.incidentChannel, func(inc *store.Incident) error {
+		change = store.RoleChange{
+			At:   time.Now(),
+			By:   params.incidentHandoverBy,
+			Role: params.role,
+			From: roleHolder(inc, params.role),
+			To:   params.newRoleHolder,
+			Note: params.note,
+		}
+		switch params.role {
+		case roleCommander:
+			inc.Commander = params.newRoleHolder
+		case roleResponder:
+			inc.Responder = params.newRoleHolder
+		default:
+			return fmt.Errorf("unknown role %q", params.role)
+		}
+		inc.RoleChanges = append(inc.RoleChanges, change)
+		inc.AddEvent(change.At, change.By, store.EventRoleChanged,
+			fmt.Sprintf("Handed over the %s role from <@%s> to <@%s>", change.Role, change.From, change.To))
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not record handover")
+		return
+	}
+
+	if _, err := h.slackClient.InviteUsersToConversationContext(ctx, inc.ChannelID, params.newRoleHolder); err != nil {
+		if err.Error() != alreadyInChannel {
+			if sendErr := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionPostEphemeral(params.incidentHandoverBy),
+				slack.MsgOptionText(fmt.Sprintf("Failed to invite <@%s> to incident channel: %s", params.newRoleHolder, err.Error()), false)); sendErr != nil {
+				log.Error().Err(sendErr).Msg(sendError)
+			}
+		}
+	}
+	h.requestAcknowledgements(ctx, inc, change.Role)
+	if err := h.setIncidentOverview(ctx, inc); err != nil {
+		if sendErr := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionPostEphemeral(params.incidentHandoverBy),
+			slack.MsgOptionText(fmt.Sprintf("Failed to update incident channel: %s", err.Error()), false)); sendErr != nil {
+			log.Error().Err(sendErr).Msg(sendError)
+		}
+	}
+
+	text := fmt.Sprintf(":handshake: The %s role of %s has been handed over from <@%s> to <@%s>",
+		change.Role, incidentLabel(inc.Number, inc.ChannelID), change.From, change.To)
+	if change.Note != "" {
+		text += fmt.Sprintf("\n*Handover note:* %s", change.Note)
+	}
+	if err := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Msg("Could not send handover to incident channel")
+	}
+	if err := h.sendMessage(ctx, inc.BroadcastChannelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Msg("Could not send handover to broadcast channel")
+	}
+}
+
+// roleHolder - who currently has the given role in the incident
+func roleHolder(inc *store.Incident, role string) string {
+	switch role {
+	case roleCommander:
+		return inc.Commander
+	case roleResponder:
+		return inc.Responder
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandoverIncident(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID: "C1",
+		Status:    store.StatusOpen,
+		Commander: "U1",
+		Declarer:  "U2",
+	}))
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:       "C2",
+		Status:          store.StatusOpen,
+		Commander:       "U1",
+		SecurityRelated: true,
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+		admins:      &ugMembers{},
+	}
+	handover := func(userID, channelID, newRoleHolder string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+			Type: slack.InteractionTypeViewSubmission,
+			User: slack.User{ID: userID},
+			View: slack.View{
+				CallbackID: "handover_incident",
+				State: &slack.ViewState{
+					Values: map[string]map[string]slack.BlockAction{
+						"incident_channel":         {"incident_channel": {SelectedConversation: channelID}},
+						"incident_role":            {"incident_role": {SelectedOption: slack.OptionBlockObject{Value: roleCommander}}},
+						"incident_new_role_holder": {"incident_new_role_holder": {SelectedUser: newRoleHolder}},
+					},
+				},
+			},
+		}))
+		return w
+	}
+
+	// anyone else can not take over a role
+	w := handover("U3", "C1", "U3")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"incident_channel":"You are not allowed to hand over the roles of this incident`)
+
+	// the roles of a security incident stay within its channel
+	c.channelMembers = []string{"U1"}
+	w = handover("U1", "C2", "U3")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), valNewHolderNotInSecurity)
+	c.channelMembers = []string{"U3"}
+	w = handover("U1", "C2", "U3")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), valNotInSecurityChannel)
+	assert.Empty(t, c.invited)
+
+	// the commander may hand over the roles
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	verrs, err := b.authorizeHandover(ctx, &slack.InteractionCallback{User: slack.User{ID: "U1"}}, inc, "U3")
+	require.NoError(t, err)
+	assert.Empty(t, verrs)
+}
+
+func TestDoHandoverTasks(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{
+		User: &slack.User{},
+	}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:          "C1",
+		BroadcastChannelID: "B1",
+		Status:             store.StatusOpen,
+		Commander:          "U1",
+		Responder:          "U2",
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+	}
+
+	b.doHandoverTasks(ctx, &handoverParams{
+		incidentChannel:    "C1",
+		role:               roleResponder,
+		newRoleHolder:      "U3",
+		incidentHandoverBy: "U2",
+	})
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, "U3", inc.Responder)
+	assert.Equal(t, "U1", inc.Commander)
+
+	b.doHandoverTasks(ctx, &handoverParams{
+		incidentChannel:    "C1",
+		role:               roleCommander,
+		newRoleHolder:      "U4",
+		note:               "Waiting for the database restore",
+		incidentHandoverBy: "U1",
+	})
+	inc, err = incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, "U4", inc.Commander)
+	require.Len(t, inc.RoleChanges, 2)
+	assert.Equal(t, store.RoleChange{
+		At:   inc.RoleChanges[1].At,
+		By:   "U1",
+		Role: roleCommander,
+		From: "U1",
+		To:   "U4",
+		Note: "Waiting for the database restore",
+	}, inc.RoleChanges[1])
+
+	assert.Contains(t, c.messages[len(c.messages)-1].Get("text"), "Waiting for the database restore")
+	assert.Equal(t, "B1", c.messages[len(c.messages)-1].Get("channel"))
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/metrics"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+// instrumentedSlackClient - a SlackClient that records metrics and logs about every call to the wrapped client
+type instrumentedSlackClient struct {
+	client  SlackClient
+	metrics *metrics.SlackAPIMetrics
+}
+
+// NewInstrumentedSlackClient - wrap a SlackClient so that the latency and outcome of every call is
+// recorded in the given metrics and logged
+func NewInstrumentedSlackClient(client SlackClient, m *metrics.SlackAPIMetrics) SlackClient {
+	return &instrumentedSlackClient{client: client, metrics: m}
+}
+
+// Slack error codes are lower case words separated by underscores, like channel_not_found
+var slackErrorCodeRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// slackErrorCode - the Slack error code of the outcome of a call, ok if it succeeded
+func slackErrorCode(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var rateLimitedErr *slack.RateLimitedError
+	if errors.As(err, &rateLimitedErr) {
+		return "ratelimited"
+	}
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) {
+		return slackErr.Err
+	}
+	var statusErr slack.StatusCodeError
+	if errors.As(err, &statusErr) {
+		return fmt.Sprintf("http_%d", statusErr.Code)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "context_done"
+	}
+	// Some of the client methods return the error code as a plain error
+	if slackErrorCodeRegex.MatchString(err.Error()) {
+		return err.Error()
+	}
+	return "unknown"
+}
+
+// observe - record a call to method that started at start, to be deferred with a pointer to the error of the call
+func (c *instrumentedSlackClient) observe(ctx context.Context, method string, start time.Time, err *error) {
+	duration := time.Since(start)
+	code := slackErrorCode(*err)
+	c.metrics.SlackAPICalled(method, code, duration)
+
+	log := zerolog.Ctx(ctx)
+	event := log.Debug()
+	if *err != nil {
+		event = log.Info().Err(*err)
+	}
+	event.Str("slack_method", method).
+		Str("slack_error", code).
+		Dur("duration", duration).
+		Msg("Slack API call")
+}
+
+func (c *instrumentedSlackClient) SendMessageContext(ctx context.Context, channelID string, options ...slack.MsgOption) (_channel, _timestamp, _text string, err error) {
+	defer c.observe(ctx, "SendMessage", time.Now(), &err)
+	return c.client.SendMessageContext(ctx, channelID, options...)
+}
+
+func (c *instrumentedSlackClient) UpdateMessageContext(ctx context.Context, channelID, timestamp string, options ...slack.MsgOption) (_channel, _timestamp, _text string, err error) {
+	defer c.observe(ctx, "UpdateMessage", time.Now(), &err)
+	return c.client.UpdateMessageContext(ctx, channelID, timestamp, options...)
+}
+
+func (c *instrumentedSlackClient) GetUserGroupMembersContext(ctx context.Context, userGroup string) (_ []string, err error) {
+	defer c.observe(ctx, "GetUserGroupMembers", time.Now(), &err)
+	return c.client.GetUserGroupMembersContext(ctx, userGroup)
+}
+
+func (c *instrumentedSlackClient) OpenViewContext(ctx context.Context, triggerID string, view slack.ModalViewRequest) (_ *slack.ViewResponse, err error) {
+	defer c.observe(ctx, "OpenView", time.Now(), &err)
+	return c.client.OpenViewContext(ctx, triggerID, view)
+}
+
+func (c *instrumentedSlackClient) UpdateViewContext(ctx context.Context, view slack.ModalViewRequest, externalID, hash, viewID string) (_ *slack.ViewResponse, err error) {
+	defer c.observe(ctx, "UpdateView", time.Now(), &err)
+	return c.client.UpdateViewContext(ctx, view, externalID, hash, viewID)
+}
+
+func (c *instrumentedSlackClient) CreateConversationContext(ctx context.Context, channelName string, isPrivate bool) (_ *slack.Channel, err error) {
+	defer c.observe(ctx, "CreateConversation", time.Now(), &err)
+	return c.client.CreateConversationContext(ctx, channelName, isPrivate)
+}
+
+func (c *instrumentedSlackClient) GetConversationInfoContext(ctx context.Context, channelID string, includeLocale bool) (_ *slack.Channel, err error) {
+	defer c.observe(ctx, "GetConversationInfo", time.Now(), &err)
+	return c.client.GetConversationInfoContext(ctx, channelID, includeLocale)
+}
+
+func (c *instrumentedSlackClient) ArchiveConversationContext(ctx context.Context, channelID string) (err error) {
+	defer c.observe(ctx, "ArchiveConversation", time.Now(), &err)
+	return c.client.ArchiveConversationContext(ctx, channelID)
+}
+
+func (c *instrumentedSlackClient) UnArchiveConversationContext(ctx context.Context, channelID string) (err error) {
+	defer c.observe(ctx, "UnArchiveConversation", time.Now(), &err)
+	return c.client.UnArchiveConversationContext(ctx, channelID)
+}
+
+func (c *instrumentedSlackClient) SetPurposeOfConversationContext(ctx context.Context, channelID, purpose string) (_ *slack.Channel, err error) {
+	defer c.observe(ctx, "SetPurposeOfConversation", time.Now(), &err)
+	return c.client.SetPurposeOfConversationContext(ctx, channelID, purpose)
+}
+
+func (c *instrumentedSlackClient) SetTopicOfConversationContext(ctx context.Context, channelID, topic string) (_ *slack.Channel, err error) {
+	defer c.observe(ctx, "SetTopicOfConversation", time.Now(), &err)
+	return c.client.SetTopicOfConversationContext(ctx, channelID, topic)
+}
+
+func (c *instrumentedSlackClient) InviteUsersToConversationContext(ctx context.Context, channelID string, users ...string) (_ *slack.Channel, err error) {
+	defer c.observe(ctx, "InviteUsersToConversation", time.Now(), &err)
+	return c.client.InviteUsersToConversationContext(ctx, channelID, users...)
+}
+
+func (c *instrumentedSlackClient) GetUserInfoContext(ctx context.Context, user string) (_ *slack.User, err error) {
+	defer c.observe(ctx, "GetUserInfo", time.Now(), &err)
+	return c.client.GetUserInfoContext(ctx, user)
+}
+
+func (c *instrumentedSlackClient) AuthTestContext(ctx context.Context) (_ *slack.AuthTestResponse, err error) {
+	defer c.observe(ctx, "AuthTest", time.Now(), &err)
+	return c.client.AuthTestContext(ctx)
+}
+
+func (c *instrumentedSlackClient) GetConversationsForUserContext(ctx context.Context, params *slack.GetConversationsForUserParameters) (_ []slack.Channel, _ string, err error) {
+	defer c.observe(ctx, "GetConversationsForUser", time.Now(), &err)
+	return c.client.GetConversationsForUserContext(ctx, params)
+}
+
+func (c *instrumentedSlackClient) GetUsersInConversationContext(ctx context.Context, params *slack.GetUsersInConversationParameters) (_ []string, _ string, err error) {
+	defer c.observe(ctx, "GetUsersInConversation", time.Now(), &err)
+	return c.client.GetUsersInConversationContext(ctx, params)
+}
+
+func (c *instrumentedSlackClient) UploadFileContext(ctx context.Context, params slack.FileUploadParameters) (_ *slack.File, err error) {
+	defer c.observe(ctx, "UploadFile", time.Now(), &err)
+	return c.client.UploadFileContext(ctx, params)
+}
+
+func (c *instrumentedSlackClient) GetPermalinkContext(ctx context.Context, params *slack.PermalinkParameters) (_ string, err error) {
+	defer c.observe(ctx, "GetPermalink", time.Now(), &err)
+	return c.client.GetPermalinkContext(ctx, params)
+}
@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/karl-johan-grahn/devopsbot/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlackErrorCode(t *testing.T) {
+	assert.Equal(t, "ok", slackErrorCode(nil))
+	assert.Equal(t, "ratelimited", slackErrorCode(&slack.RateLimitedError{}))
+	assert.Equal(t, "channel_not_found", slackErrorCode(slack.SlackErrorResponse{Err: "channel_not_found"}))
+	assert.Equal(t, "missing_scope", slackErrorCode(fmt.Errorf("failed: %w", slack.SlackErrorResponse{Err: "missing_scope"})))
+	assert.Equal(t, "http_503", slackErrorCode(slack.StatusCodeError{Code: 503, Status: "Service Unavailable"}))
+	assert.Equal(t, "already_in_channel", slackErrorCode(errors.New("already_in_channel")))
+	assert.Equal(t, "context_done", slackErrorCode(context.DeadlineExceeded))
+	assert.Equal(t, "unknown", slackErrorCode(errors.New("something bad")))
+}
+
+func TestInstrumentedSlackClient(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics.MetricsRegisterer = reg
+	defer func() { metrics.MetricsRegisterer = prometheus.DefaultRegisterer }()
+
+	ctx := context.TODO()
+	c := &dummyClient{}
+	client := NewInstrumentedSlackClient(c, metrics.NewSlackAPIMetrics("test"))
+
+	_, _, _, err := client.SendMessageContext(ctx, "C1", slack.MsgOptionText("hello", false))
+	require.NoError(t, err)
+	require.Len(t, c.messages, 1)
+
+	c.err = errors.New("not_in_channel")
+	err = client.ArchiveConversationContext(ctx, "C1")
+	assert.EqualError(t, err, "not_in_channel")
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP test_slack_api_calls_total The number of calls to the Slack API by method and Slack error code, ok for successful calls
+# TYPE test_slack_api_calls_total counter
+test_slack_api_calls_total{error="not_in_channel",method="ArchiveConversation"} 1
+test_slack_api_calls_total{error="ok",method="SendMessage"} 1
+`), "test_slack_api_calls_total"))
+	count, err := testutil.GatherAndCount(reg, "test_slack_api_call_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -7,22 +7,27 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/karl-johan-grahn/devopsbot/internal/middleware"
 	"github.com/karl-johan-grahn/devopsbot/internal/wrappedcontext"
+	"github.com/karl-johan-grahn/devopsbot/store"
 	"github.com/rs/zerolog"
 	"github.com/slack-go/slack"
 )
 
 var channelNameRegex = regexp.MustCompile(`^[a-z0-9\-\_]+$`)
-var incChannelNameRegex = regexp.MustCompile(`^inc_`)
 
 const (
 	alreadyInChannel   = "already_in_channel"
 	valIncChName       = "\"%s\" - channel name must be non-empty, and contain only lowercase letters, numbers, hyphens, and underscores"
 	valChosenIncChName = "#%s does not seem to be an incident channel"
+	valUnknownIncident = "The chosen channel is not a recorded incident channel"
+	valIncidentNotOpen = "The chosen incident has already been resolved"
+
+	securityIncidentMessage = "This is a security related incident - available by invitation only"
 )
 
 // handleInteractive - a general handler for the /interactive endpoint
@@ -57,19 +62,49 @@ func (h *botHandler) handleInteractive(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 			} else {
+				// The number is only reserved on submission, so it is a best guess
+				lastNumber, err := h.incidents.LastNumber(ctx)
+				if err != nil {
+					log.Error().Err(err).Msg("Could not get last incident number")
+				}
+				channelName, err := h.channelNamer().Name(lastNumber+1, action.Value, time.Now())
+				if err != nil {
+					log.Error().Err(err).Msg("Could not create channel name")
+				}
 				if uerr := h.updateView(ctx, payload, "incident_name", "declare_incident",
-					fmt.Sprintf("This will create this channel name: #%s", createChannelName(action.Value)), w); uerr != nil {
+					fmt.Sprintf("This will create this channel name: #%s", channelName), w); uerr != nil {
 					uerr = middleware.NewHTTPError(uerr, r)
 					log.Error().Err(uerr).Msg("updateView failed")
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
 			}
+		case broadcastActionsBlockID:
+			if err := h.handleBroadcastAction(ctx, payload, action); err != nil {
+				err = middleware.NewHTTPError(err, r)
+				log.Error().Err(err).Msg("handleBroadcastAction failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		case controlsBlockID:
+			if err := h.handleControlAction(ctx, payload, action); err != nil {
+				err = middleware.NewHTTPError(err, r)
+				log.Error().Err(err).Msg("handleControlAction failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		case acknowledgeBlockID:
+			if err := h.handleAcknowledgeAction(ctx, payload, action); err != nil {
+				err = middleware.NewHTTPError(err, r)
+				log.Error().Err(err).Msg("handleAcknowledgeAction failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
 		case "incident_channel":
 			channelID := action.SelectedConversation
 			channel, _ := h.slackClient.GetConversationInfoContext(ctx, channelID, false)
-			if err := validateChosenIncidentChannelName("incident_channel", channel.Name); err != nil {
-				if uerr := h.updateView(ctx, payload, "incident_channel", "resolve_incident", fmt.Sprintf(valChosenIncChName, channel.Name), w); uerr != nil {
+			if err := h.validateChosenIncidentChannelName("incident_channel", channel.Name); err != nil {
+				if uerr := h.updateView(ctx, payload, "incident_channel", payload.View.CallbackID, fmt.Sprintf(valChosenIncChName, channel.Name), w); uerr != nil {
 					uerr = middleware.NewHTTPError(uerr, r)
 					log.Error().Err(uerr).Msg("updateView failed")
 					w.WriteHeader(http.StatusInternalServerError)
@@ -82,6 +117,19 @@ func (h *botHandler) handleInteractive(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		w.WriteHeader(http.StatusOK)
+	case slack.InteractionTypeBlockSuggestion:
+		switch payload.ActionID {
+		case "broadcast_channel":
+			if err := h.broadcastChannelSuggestions(ctx, payload, w); err != nil {
+				err = middleware.NewHTTPError(err, r)
+				log.Error().Err(err).Msg("broadcastChannelSuggestions failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		default:
+			log.Error().Str("ActionID", payload.ActionID).Msg("unknown ActionID")
+			w.WriteHeader(http.StatusBadRequest)
+		}
 	case slack.InteractionTypeViewSubmission:
 		callbackID := payload.View.CallbackID
 		if callbackID == "" {
@@ -93,6 +141,9 @@ func (h *botHandler) handleInteractive(w http.ResponseWriter, r *http.Request) {
 		switch callbackID {
 		case "declare_incident":
 			if err := h.declareIncident(ctx, payload, w); err != nil {
+				if slackUnavailableResponse(ctx, payload, err, w) {
+					return
+				}
 				err = middleware.NewHTTPError(err, r)
 				log.Error().Err(err).Msg("declareIncident failed")
 				w.WriteHeader(http.StatusInternalServerError)
@@ -100,11 +151,54 @@ func (h *botHandler) handleInteractive(w http.ResponseWriter, r *http.Request) {
 			}
 		case "resolve_incident":
 			if err := h.resolveIncident(ctx, payload, w); err != nil {
+				if slackUnavailableResponse(ctx, payload, err, w) {
+					return
+				}
 				err = middleware.NewHTTPError(err, r)
 				log.Error().Err(err).Msg("resolveIncident failed")
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
+		case "reopen_incident":
+			if err := h.reopenIncident(ctx, payload, w); err != nil {
+				if slackUnavailableResponse(ctx, payload, err, w) {
+					return
+				}
+				err = middleware.NewHTTPError(err, r)
+				log.Error().Err(err).Msg("reopenIncident failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		case "escalate_incident":
+			if err := h.escalateIncident(ctx, payload, w); err != nil {
+				if slackUnavailableResponse(ctx, payload, err, w) {
+					return
+				}
+				err = middleware.NewHTTPError(err, r)
+				log.Error().Err(err).Msg("escalateIncident failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		case "handover_incident":
+			if err := h.handoverIncident(ctx, payload, w); err != nil {
+				if slackUnavailableResponse(ctx, payload, err, w) {
+					return
+				}
+				err = middleware.NewHTTPError(err, r)
+				log.Error().Err(err).Msg("handoverIncident failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		case "update_incident":
+			if err := h.postIncidentUpdate(ctx, payload, w); err != nil {
+				if slackUnavailableResponse(ctx, payload, err, w) {
+					return
+				}
+				err = middleware.NewHTTPError(err, r)
+				log.Error().Err(err).Msg("postIncidentUpdate failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
 		default:
 			log.Error().Str("callbackID", callbackID).Msg("unknown callbackID")
 			w.WriteHeader(http.StatusBadRequest)
@@ -143,13 +237,14 @@ func (h *botHandler) updateView(ctx context.Context, payload *slack.InteractionC
 }
 
 type inputParams struct {
+	incidentNumber               int
 	incidentChannelName          string
 	incidentSecurityRelated      bool
 	incidentResponder            string
 	incidentCommander            string
 	incidentInvitees             []string
-	incidentEnvironmentsAffected string
-	incidentRegionsAffected      string
+	incidentEnvironmentsAffected []string
+	incidentRegionsAffected      []string
 	IncidentSeverityLevel        string
 	IncidentImpactLevel          string
 	incidentSummary              string
@@ -172,10 +267,46 @@ func (e *validationError) Error() string {
 	return fmt.Sprintf("validation errors with field(s): %s", strings.Join(badFields, ", "))
 }
 
+// incidentOverview - the overview of an incident that is used as purpose and topic of the incident channel
+func incidentOverview(inc *store.Incident) string {
+	var securityMessage string
+	if inc.SecurityRelated {
+		securityMessage = securityIncidentMessage
+	}
+	var incident string
+	if inc.Number > 0 {
+		incident = fmt.Sprintf("*Incident:* %s\n", incidentRef(inc.Number))
+	}
+	return fmt.Sprintf("%s*Environment affected:* %s\n"+
+		"*Region affected:* %s\n"+
+		"*Severity:* %s\n"+
+		"*Impact:* %s\n"+
+		"*Responder:* <@%s>\n"+
+		"*Commander:* <@%s>\n"+
+		"*Broadcast channel:* <#%s>\n\n"+
+		"Declared by: <@%s>\n"+
+		"%s",
+		incident, strings.Join(inc.Environments, ", "), strings.Join(inc.Regions, ", "),
+		inc.Severity, inc.Impact,
+		inc.Responder, inc.Commander, inc.BroadcastChannelID, inc.Declarer,
+		securityMessage)
+}
+
+// setIncidentOverview - set the purpose and topic of the incident channel to the incident overview
+func (h *botHandler) setIncidentOverview(ctx context.Context, inc *store.Incident) error {
+	overview := incidentOverview(inc)
+	if _, err := h.slackClient.SetPurposeOfConversationContext(ctx, inc.ChannelID, overview); err != nil {
+		return fmt.Errorf("failed to set purpose for incident channel: %w", err)
+	}
+	if _, err := h.slackClient.SetTopicOfConversationContext(ctx, inc.ChannelID, overview); err != nil {
+		return fmt.Errorf("failed to set topic for incident channel: %w", err)
+	}
+	return nil
+}
+
 // validatePayload - validate incident payload
 func validatePayload(ctx context.Context, payload *slack.InteractionCallback) error {
-	incidentChannelName := createChannelName(payload.View.State.Values["incident_name"]["incident_name"].Value)
-	return validateIncidentChannelName("incident_name", incidentChannelName)
+	return validateIncidentChannelName("incident_name", payload.View.State.Values["incident_name"]["incident_name"].Value)
 }
 
 // declareIncident - general handler for incident commands
@@ -187,7 +318,21 @@ func (h *botHandler) declareIncident(ctx context.Context, payload *slack.Interac
 		}
 		return err
 	}
-	incidentChannelName := createChannelName(payload.View.State.Values["incident_name"]["incident_name"].Value)
+	incidentNumber, err := h.incidents.NextNumber(ctx)
+	if err != nil {
+		return err
+	}
+	incidentChannelName, err := h.channelNamer().Name(incidentNumber, payload.View.State.Values["incident_name"]["incident_name"].Value, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := validateIncidentChannelName("incident_name", incidentChannelName); err != nil {
+		var verr *validationError
+		if errors.As(err, &verr) {
+			return postErrorResponse(ctx, verr.errors, w)
+		}
+		return err
+	}
 	incidentEnvironmentsAffected := make([]string, len(payload.View.State.Values["incident_environment_affected"]["incident_environment_affected"].SelectedOptions))
 	for i, e := range payload.View.State.Values["incident_environment_affected"]["incident_environment_affected"].SelectedOptions {
 		incidentEnvironmentsAffected[i] = e.Value
@@ -202,13 +347,14 @@ func (h *botHandler) declareIncident(ctx context.Context, payload *slack.Interac
 	}
 	inputParams := &inputParams{
 		broadcastChannel:             payload.View.State.Values["broadcast_channel"]["broadcast_channel"].SelectedOption.Value,
+		incidentNumber:               incidentNumber,
 		incidentChannelName:          incidentChannelName,
 		incidentSecurityRelated:      incidentSecurityRelated,
 		incidentResponder:            payload.View.State.Values["incident_responder"]["incident_responder"].SelectedUser,
 		incidentCommander:            payload.View.State.Values["incident_commander"]["incident_commander"].SelectedUser,
 		incidentInvitees:             payload.View.State.Values["incident_invitees"]["incident_invitees"].SelectedUsers,
-		incidentEnvironmentsAffected: strings.Join(incidentEnvironmentsAffected, ", "),
-		incidentRegionsAffected:      strings.Join(incidentRegionsAffected, ", "),
+		incidentEnvironmentsAffected: incidentEnvironmentsAffected,
+		incidentRegionsAffected:      incidentRegionsAffected,
 		IncidentSeverityLevel:        payload.View.State.Values["incident_severity_level"]["incident_severity_level"].SelectedOption.Value,
 		IncidentImpactLevel:          payload.View.State.Values["incident_impact_level"]["incident_impact_level"].SelectedOption.Value,
 		incidentSummary:              payload.View.State.Values["incident_summary"]["incident_summary"].Value,
@@ -228,8 +374,8 @@ func (h *botHandler) declareIncident(ctx context.Context, payload *slack.Interac
 
 	w.WriteHeader(http.StatusAccepted)
 
-	// Do the rest via goroutine
-	ctx = wrappedcontext.WrapContextValues(context.Background(), ctx)
+	// Do the rest via goroutine, which outlives the request and can wait for Slack to recover
+	ctx = waitForSlack(wrappedcontext.WrapContextValues(context.Background(), ctx))
 	go h.doIncidentTasks(ctx, inputParams, incidentChannel)
 
 	return nil
@@ -239,25 +385,37 @@ func (h *botHandler) declareIncident(ctx context.Context, payload *slack.Interac
 func (h *botHandler) doIncidentTasks(ctx context.Context, params *inputParams, incidentChannel *slack.Channel) {
 	log := zerolog.Ctx(ctx)
 	const sendError = "Could not send failure message"
-	var securityMessage string
-	if params.incidentSecurityRelated {
-		securityMessage = "This is a security related incident - available by invitation only"
+	// Record the incident so that it can be looked up after this function returns
+	inc := &store.Incident{
+		ChannelID:          incidentChannel.ID,
+		Number:             params.incidentNumber,
+		ChannelName:        params.incidentChannelName,
+		BroadcastChannelID: params.broadcastChannel,
+		Status:             store.StatusOpen,
+		SecurityRelated:    params.incidentSecurityRelated,
+		Declarer:           params.incidentDeclarer,
+		Responder:          params.incidentResponder,
+		Commander:          params.incidentCommander,
+		Severity:           params.IncidentSeverityLevel,
+		Impact:             params.IncidentImpactLevel,
+		Environments:       params.incidentEnvironmentsAffected,
+		Regions:            params.incidentRegionsAffected,
+		Summary:            params.incidentSummary,
+		DeclaredAt:         time.Now(),
+	}
+	inc.AddEvent(inc.DeclaredAt, inc.Declarer, store.EventDeclared, "Declared the incident: "+inc.Summary)
+	if err := h.incidents.Create(ctx, inc); err != nil {
+		log.Error().Err(err).Msg("Could not record incident")
+		if sendErr := h.sendMessage(ctx, params.broadcastChannel, slack.MsgOptionPostEphemeral(params.incidentDeclarer),
+			slack.MsgOptionText(fmt.Sprintf("Failed to record incident: %s", err.Error()), false)); sendErr != nil {
+			log.Error().Err(sendErr).Msg(sendError)
+			return
+		}
 	} else {
-		securityMessage = ""
+		h.opts.Metrics.IncidentDeclared(inc.Severity, inc.Impact, inc.Environments, inc.SecurityRelated)
 	}
 	// Set channel purpose and topic - they can be maximum 250 characters
-	overview := fmt.Sprintf("*Environment affected:* %s\n"+
-		"*Region affected:* %s\n"+
-		"*Severity:* %s\n"+
-		"*Impact:* %s\n"+
-		"*Responder:* <@%s>\n"+
-		"*Commander:* <@%s>\n"+
-		"*Broadcast channel:* <#%s>\n\n"+
-		"Declared by: <@%s>\n"+
-		securityMessage,
-		params.incidentEnvironmentsAffected, params.incidentRegionsAffected,
-		params.IncidentSeverityLevel, params.IncidentImpactLevel,
-		params.incidentResponder, params.incidentCommander, params.broadcastChannel, params.incidentDeclarer)
+	overview := incidentOverview(inc)
 	if _, err := h.slackClient.SetPurposeOfConversationContext(ctx, incidentChannel.ID, overview); err != nil {
 		if sendErr := h.sendMessage(ctx, params.broadcastChannel, slack.MsgOptionPostEphemeral(params.incidentDeclarer),
 			slack.MsgOptionText(fmt.Sprintf("Failed to set purpose for incident channel: %s", err.Error()), false)); sendErr != nil {
@@ -283,25 +441,29 @@ func (h *botHandler) doIncidentTasks(ctx context.Context, params *inputParams, i
 			}
 		}
 	}
-	// Inform about incident
-	if err := h.sendMessage(ctx, params.broadcastChannel,
-		slack.MsgOptionText(fmt.Sprintf(":rotating_siren: An incident has been declared by <@%s>\n"+
-			"*Incident summary:* %s\n"+
-			"*Environment affected:* %s\n"+
-			"*Region affected:* %s\n"+
-			"*Severity:* %s\n"+
-			"*Impact:* %s\n"+
-			"*Responder:* <@%s>\n"+
-			"*Commander:* <@%s>\n"+
-			"*Incident channel:* <#%s>\n"+
-			securityMessage,
-			params.incidentDeclarer, params.incidentSummary, params.incidentEnvironmentsAffected,
-			params.incidentRegionsAffected, params.IncidentSeverityLevel, params.IncidentImpactLevel,
-			params.incidentResponder, params.incidentCommander,
-			incidentChannel.ID), false)); err != nil {
+	// Make sure the responder and commander noticed that they were given the roles
+	h.requestAcknowledgements(ctx, inc, roleResponder, roleCommander)
+	// Inform about incident, the text is the fallback for notifications
+	broadcastTS, err := h.postMessage(ctx, params.broadcastChannel,
+		slack.MsgOptionText(declarationText(inc), false),
+		slack.MsgOptionBlocks(h.declarationBlocks(inc)...))
+	if err != nil {
 		log.Error().Err(err).Msg(sendError)
 		return
 	}
+	// Keep track of the announcement so that it can be updated
+	if _, err := h.incidents.Update(ctx, inc.ChannelID, func(inc *store.Incident) error {
+		inc.BroadcastTS = broadcastTS
+		return nil
+	}); err != nil {
+		log.Error().Err(err).Msg("Could not record broadcast announcement")
+	}
+	// Give the incident channel buttons for managing the incident
+	if err := h.sendMessage(ctx, incidentChannel.ID,
+		slack.MsgOptionText(controlText(inc), false),
+		slack.MsgOptionBlocks(h.controlBlocks(inc)...)); err != nil {
+		log.Error().Err(err).Msg("Could not send control message to incident channel")
+	}
 	// Send message about starting a video call for live troubleshooting
 	if err := h.sendMessage(ctx, incidentChannel.ID,
 		slack.MsgOptionText(fmt.Sprintf("IC <@%s>: Start an incident Teams call with the command `/teams-calls meeting %s` and invite the appropriate people", params.incidentCommander, params.incidentChannelName), false)); err != nil {
@@ -314,28 +476,6 @@ func (h *botHandler) doIncidentTasks(ctx context.Context, params *inputParams, i
 		log.Error().Err(err).Msg(sendError)
 		return
 	}
-	// Add channel reminder about updating progress
-	// Need to use user access token since bot token is not allowed token type: https://api.slack.com/methods/reminders.add
-	userSlackClient := slack.New(h.opts.UserAccessToken)
-	user, err := h.slackClient.GetUserInfoContext(ctx, params.incidentDeclarer)
-	if err != nil {
-		if sendErr := h.sendMessage(ctx, incidentChannel.ID, slack.MsgOptionPostEphemeral(params.incidentDeclarer),
-			slack.MsgOptionText(fmt.Sprintf("Failed to get user info context: %s", err.Error()), false)); sendErr != nil {
-			log.Error().Err(sendErr).Msg(sendError)
-			return
-		}
-	}
-	loc := time.FixedZone("CUSTOM-TZ", user.TZOffset)
-	now := time.Now().In(loc)
-	if _, err := userSlackClient.AddChannelReminder(incidentChannel.ID,
-		fmt.Sprintf("\"Reminder for IC <@%s>: Update progress about the incident every 30 min in <#%s>, or remove the reminder and archive the channel if the incident is resolved\"", params.incidentCommander, params.broadcastChannel),
-		fmt.Sprintf("every day at %s", now.Add(time.Minute*time.Duration(30)).Format("03:04:05PM"))); err != nil {
-		if sendErr := h.sendMessage(ctx, incidentChannel.ID, slack.MsgOptionPostEphemeral(params.incidentDeclarer),
-			slack.MsgOptionText(fmt.Sprintf("Failed to add channel reminder: %s", err.Error()), false)); sendErr != nil {
-			log.Error().Err(sendErr).Msg(sendError)
-			return
-		}
-	}
 }
 
 type resolveParams struct {
@@ -353,14 +493,32 @@ type resolveParams struct {
 
 // resolveIncident - handler for resolving incidents
 func (h *botHandler) resolveIncident(ctx context.Context, payload *slack.InteractionCallback, w http.ResponseWriter) error {
-	incidentChannelID := payload.View.State.Values["incident_channel"]["incident_channel"].SelectedConversation
-	incChannel, _ := h.slackClient.GetConversationInfoContext(ctx, incidentChannelID, false)
-	if err := validateChosenIncidentChannelName("incident_channel", incChannel.Name); err != nil {
-		var verr *validationError
-		if errors.As(err, &verr) {
-			return postErrorResponse(ctx, verr.errors, w)
+	incidentChannelID := submittedIncidentChannel(payload)
+	// A modal scoped to an incident has no channel to choose, so the name needs no check
+	if payload.View.PrivateMetadata == "" {
+		incChannel, _ := h.slackClient.GetConversationInfoContext(ctx, incidentChannelID, false)
+		if err := h.validateChosenIncidentChannelName("incident_channel", incChannel.Name); err != nil {
+			var verr *validationError
+			if errors.As(err, &verr) {
+				return postErrorResponse(ctx, verr.errors, w)
+			}
+			return err
 		}
+	}
+	// Incidents that were never recorded can still be resolved, recorded ones only while they are open
+	if _, err := h.incidents.Get(ctx, incidentChannelID); !errors.Is(err, store.ErrNotFound) {
+		if err := h.validateOpenIncident(ctx, incidentField(payload, "resolution"), incidentChannelID); err != nil {
+			var verr *validationError
+			if errors.As(err, &verr) {
+				return postErrorResponse(ctx, verr.errors, w)
+			}
+			return err
+		}
+	}
+	if verrs, err := h.authorizeResolve(ctx, payload, incidentChannelID); err != nil {
 		return err
+	} else if len(verrs) > 0 {
+		return postErrorResponse(ctx, verrs, w)
 	}
 	resolveParams := &resolveParams{
 		broadcastChannel:   payload.View.State.Values["broadcast_channel"]["broadcast_channel"].SelectedOption.Value,
@@ -372,41 +530,137 @@ func (h *botHandler) resolveIncident(ctx context.Context, payload *slack.Interac
 
 	w.WriteHeader(http.StatusAccepted)
 
-	// Do the rest via goroutine
-	ctx = wrappedcontext.WrapContextValues(context.Background(), ctx)
+	// Do the rest via goroutine, which outlives the request and can wait for Slack to recover
+	ctx = waitForSlack(wrappedcontext.WrapContextValues(context.Background(), ctx))
 	go h.doResolveTasks(ctx, resolveParams)
 
 	return nil
 }
 
+// authorizeResolve - check that the user may resolve the incident of a submitted resolve modal, and
+// archive its channel if chosen, giving the refusals by field
+func (h *botHandler) authorizeResolve(ctx context.Context, payload *slack.InteractionCallback, incidentChannelID string) (map[string]string, error) {
+	// Incidents that were never recorded have no roles, so only the roles that do not depend on the incident apply
+	inc, err := h.incidents.Get(ctx, incidentChannelID)
+	if errors.Is(err, store.ErrNotFound) {
+		inc = nil
+	} else if err != nil {
+		return nil, err
+	}
+	verrs := map[string]string{}
+	if refusal, ok := h.authorize(ctx, payload.User.ID, ActionResolve, inc); !ok {
+		verrs[incidentField(payload, "resolution")] = refusal
+	}
+	if payload.View.State.Values["archive_choice"]["archive_choice"].SelectedOption.Value == "Yes" {
+		if refusal, ok := h.authorize(ctx, payload.User.ID, ActionArchive, inc); !ok {
+			verrs["archive_choice"] = refusal
+		}
+	}
+	return verrs, nil
+}
+
 func (h *botHandler) doResolveTasks(ctx context.Context, params *resolveParams) {
 	log := zerolog.Ctx(ctx)
+	inc, err := h.incidents.Resolve(ctx, params.incidentChannel, params.incidentResolver, params.incidentResolution, time.Now())
+	switch {
+	// Incidents declared before the store existed can still be resolved
+	case errors.Is(err, store.ErrNotFound):
+		log.Warn().Str("incident_channel", params.incidentChannel).Msg("Resolving an incident that was never recorded")
+	// Resolved in the meantime, so it is neither counted nor announced twice
+	case errors.Is(err, store.ErrNotOpen):
+		log.Warn().Str("incident_channel", params.incidentChannel).Msg("Incident already resolved")
+		if sendErr := h.sendMessage(ctx, params.incidentChannel, slack.MsgOptionPostEphemeral(params.incidentResolver),
+			slack.MsgOptionText(valIncidentNotOpen, false)); sendErr != nil {
+			log.Error().Err(sendErr).Msg("Could not send failure message")
+		}
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Could not record incident resolution")
+	}
+	number := 0
+	// The postmortem must be uploaded before the channel may be archived, the store only gives back the incident
+	// when it recorded the resolution
+	if inc != nil {
+		number = inc.Number
+		var timeToResolve time.Duration
+		if !inc.DeclaredAt.IsZero() {
+			timeToResolve = inc.ResolvedAt.Sub(inc.DeclaredAt)
+		}
+		h.opts.Metrics.IncidentResolved(inc.Severity, inc.Impact, inc.Environments, inc.SecurityRelated, timeToResolve)
+		if err := h.uploadPostmortem(ctx, inc); err != nil {
+			log.Error().Err(err).Msg("Could not upload postmortem")
+			if sendErr := h.sendMessage(ctx, params.incidentChannel, slack.MsgOptionPostEphemeral(params.incidentResolver),
+				slack.MsgOptionText(fmt.Sprintf("Failed to upload postmortem: %s", err.Error()), false)); sendErr != nil {
+				log.Error().Err(sendErr).Msg("Could not send failure message")
+			}
+		}
+	}
 	// Inform about resolution
-	if err := h.sendMessage(ctx, params.broadcastChannel,
-		slack.MsgOptionText(fmt.Sprintf(":white_check_mark: The incident <#%s> has been resolved!\n"+
-			"*Resolution:* %s",
-			params.incidentChannel, params.incidentResolution), false)); err != nil {
+	text := fmt.Sprintf(":white_check_mark: The incident %s has been resolved!\n"+
+		"*Resolution:* %s",
+		incidentLabel(number, params.incidentChannel), params.incidentResolution)
+	ts, err := h.postMessage(ctx, params.broadcastChannel, slack.MsgOptionText(text, false))
+	if err != nil {
 		log.Error().Err(err).Msg("Could not send failure message")
 		return
 	}
+	if inc != nil {
+		h.notifySubscribers(ctx, inc, text)
+		h.deleteChannelReminder(ctx, inc)
+		// Keep track of the resolution message so that a reopening can link to it
+		if _, err := h.incidents.Update(ctx, inc.ChannelID, func(inc *store.Incident) error {
+			inc.ResolutionChannelID = params.broadcastChannel
+			inc.ResolutionTS = ts
+			return nil
+		}); err != nil {
+			log.Error().Err(err).Msg("Could not record resolution message")
+		}
+	}
 	if params.incidentArchive {
 		if err := h.slackClient.ArchiveConversationContext(ctx, params.incidentChannel); err != nil {
 			log.Error().Err(err).Msg("Could not archive channel")
 			return
 		}
+		if _, err := h.incidents.Update(ctx, params.incidentChannel, func(inc *store.Incident) error {
+			inc.AddEvent(time.Now(), params.incidentResolver, store.EventArchived, "Archived the incident channel")
+			return nil
+		}); err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Error().Err(err).Msg("Could not record archiving of incident channel")
+		}
 	}
 }
 
 // sendMessage - a simplified way to send a message
 func (h *botHandler) sendMessage(ctx context.Context, channelID string, options ...slack.MsgOption) error {
+	_, err := h.postMessage(ctx, channelID, options...)
+	return err
+}
+
+// postMessage - send a message and return its timestamp, which identifies the message in the channel
+func (h *botHandler) postMessage(ctx context.Context, channelID string, options ...slack.MsgOption) (string, error) {
 	log := zerolog.Ctx(ctx)
 	ch, ts, txt, err := h.slackClient.SendMessageContext(ctx, channelID, options...)
 	if err != nil {
 		err = fmt.Errorf("failed to send message: %w", err)
 		log.Error().Err(err).Str("channel", ch).Str("message_timestamp", ts).Str("message_text", txt).Send()
-		return err
+		return "", err
 	}
-	return nil
+	return ts, nil
+}
+
+// slackUnavailableResponse - show ErrSlackUnavailable at the first input of a submitted modal, so that it can be
+// submitted again later. Returns false if err is another error or the modal has no input.
+func slackUnavailableResponse(ctx context.Context, payload *slack.InteractionCallback, err error, w http.ResponseWriter) bool {
+	if !errors.Is(err, ErrSlackUnavailable) {
+		return false
+	}
+	for _, block := range payload.View.Blocks.BlockSet {
+		if input, ok := block.(*slack.InputBlock); ok {
+			_ = postErrorResponse(ctx, map[string]string{input.BlockID: ErrSlackUnavailable.Error()}, w)
+			return true
+		}
+	}
+	return false
 }
 
 func postErrorResponse(ctx context.Context, verr map[string]string, w http.ResponseWriter) error {
@@ -429,9 +683,32 @@ func postErrorResponse(ctx context.Context, verr map[string]string, w http.Respo
 	return nil
 }
 
-// createChannelName - create incident channel name based on input field
-func createChannelName(s string) string {
-	return fmt.Sprintf("inc_%s_%s", s, strings.ToLower(time.Now().Format("2Jan2006")))
+// incidentRef - the human friendly identifier of an incident, for example INC-42
+func incidentRef(number int) string {
+	return fmt.Sprintf("INC-%d", number)
+}
+
+// parseIncidentRef - parse an incident identifier like INC-42, inc-42, #42 or 42
+func parseIncidentRef(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) > 4 && strings.EqualFold(s[:4], "inc-") {
+		s = s[4:]
+	}
+	s = strings.TrimPrefix(s, "#")
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// incidentLabel - how an incident is referred to in messages, incidents recorded
+// before numbering only have their channel
+func incidentLabel(number int, channelID string) string {
+	if number == 0 {
+		return fmt.Sprintf("<#%s>", channelID)
+	}
+	return fmt.Sprintf("%s <#%s>", incidentRef(number), channelID)
 }
 
 // createUserFriendlyConversationError - Map https://api.slack.com/methods/conversations.create error codes to user friendly messages
@@ -454,9 +731,10 @@ func validateIncidentChannelName(field string, n string) error {
 	return nil
 }
 
-func validateChosenIncidentChannelName(field string, n string) error {
+// validateChosenIncidentChannelName - validate that the chosen channel is named like an incident channel
+func (h *botHandler) validateChosenIncidentChannelName(field string, n string) error {
 	errorMessage := make(map[string]string)
-	if !incChannelNameRegex.MatchString(n) {
+	if !h.channelNamer().Matches(n) {
 		errorMessage[field] = fmt.Sprintf(valChosenIncChName, n)
 		return &validationError{
 			errors: errorMessage,
@@ -19,3 +19,20 @@ func TestValidateIncidentChannelName(t *testing.T) {
 	err = validateIncidentChannelName("special_chars_invalid", incidentChannelName)
 	assert.Error(t, err)
 }
+
+func TestParseIncidentRef(t *testing.T) {
+	for _, s := range []string{"INC-42", "inc-42", "#42", "42", " INC-42 "} {
+		n, ok := parseIncidentRef(s)
+		assert.True(t, ok, s)
+		assert.Equal(t, 42, n, s)
+	}
+	for _, s := range []string{"", "INC-", "INC-0", "INC--1", "json", "inc_42"} {
+		_, ok := parseIncidentRef(s)
+		assert.False(t, ok, s)
+	}
+}
+
+func TestIncidentLabel(t *testing.T) {
+	assert.Equal(t, "INC-42 <#C1>", incidentLabel(42, "C1"))
+	assert.Equal(t, "<#C1>", incidentLabel(0, "C1"))
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/oncall"
+	"github.com/slack-go/slack"
+)
+
+// responderTeam - the team whose on-call person is the suggested responder, the only team if not configured
+func (h *botHandler) responderTeam() string {
+	if h.opts.OnCallResponderTeam != "" {
+		return h.opts.OnCallResponderTeam
+	}
+	if len(h.opts.OnCall) == 1 {
+		return h.opts.OnCall.Teams()[0]
+	}
+	return ""
+}
+
+// onCallResponder - who is on call at now in the responder team, "" if nobody is
+func (h *botHandler) onCallResponder(now time.Time) string {
+	schedule, ok := h.opts.OnCall[h.responderTeam()]
+	if !ok {
+		return ""
+	}
+	shift, ok := schedule.At(now)
+	if !ok {
+		return ""
+	}
+	return shift.User
+}
+
+// cmdOnCall - handler for showing who is on call now and next, in every team or only in the given one
+func (h *botHandler) cmdOnCall(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, team string) error {
+	if len(h.opts.OnCall) == 0 {
+		return h.errorResponse(ctx, w, cmd, "No on-call schedules are configured", nil)
+	}
+	teams := h.opts.OnCall.Teams()
+	if team = strings.ToLower(strings.TrimSpace(team)); team != "" {
+		if _, ok := h.opts.OnCall[team]; !ok {
+			return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Unknown team %q, the teams with an on-call schedule are %s",
+				team, strings.Join(teams, ", ")), nil)
+		}
+		teams = []string{team}
+	}
+
+	now := time.Now()
+	lines := []string{}
+	for _, team := range teams {
+		lines = append(lines, onCallText(h.opts.OnCall[team], now))
+	}
+	if err := h.respond(ctx, cmd.ResponseURL, cmd.UserID, slack.ResponseTypeEphemeral,
+		slack.MsgOptionText(strings.Join(lines, "\n"), false),
+		slack.MsgOptionAttachments(),
+	); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// onCallText - who is on call in a team at now and who is next
+func onCallText(schedule *oncall.Schedule, now time.Time) string {
+	text := fmt.Sprintf(":pager: *%s*: ", schedule.Team)
+	if current, ok := schedule.At(now); ok {
+		text += fmt.Sprintf("<@%s> until %s", current.User, slackDate(current.End))
+	} else {
+		text += "nobody is on call"
+	}
+	if next, ok := schedule.Next(now); ok {
+		text += fmt.Sprintf(", next <@%s> from %s", next.User, slackDate(next.Start))
+	}
+	return text
+}
+
+// slackDate - a date and time that Slack shows in the time zone of the reader
+func slackDate(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>", t.Unix(), t.UTC().Format("Mon 2 Jan 15:04 MST"))
+}
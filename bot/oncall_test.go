@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/oncall"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSchedules - schedules where U1 is on call in platform and U2 in database, each for a day from now
+func newTestSchedules(t *testing.T) oncall.Schedules {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	paths := map[string]string{}
+	for team, user := range map[string]string{"platform": "U1", "database": "U2"} {
+		path := filepath.Join(dir, team+".yaml")
+		require.NoError(t, os.WriteFile(path, []byte("rotations:\n  - start: "+start+"\n    shiftLength: 24h\n    users: ["+user+", U9]\n"), 0o600))
+		paths[team] = path
+	}
+	schedules, err := oncall.LoadSchedules(paths)
+	require.NoError(t, err)
+	return schedules
+}
+
+func TestResponderTeam(t *testing.T) {
+	schedules := newTestSchedules(t)
+	b := &botHandler{}
+	assert.Empty(t, b.onCallResponder(time.Now()))
+
+	b.opts.OnCall = schedules
+	assert.Empty(t, b.responderTeam())
+	b.opts.OnCallResponderTeam = "database"
+	assert.Equal(t, "U2", b.onCallResponder(time.Now()))
+
+	b.opts = Opts{OnCall: oncall.Schedules{"platform": schedules["platform"]}}
+	assert.Equal(t, "platform", b.responderTeam())
+	assert.Equal(t, "U1", b.onCallResponder(time.Now()))
+}
+
+func TestCmdOnCall(t *testing.T) {
+	c := &dummyClient{User: &slack.User{Locale: "en-US"}}
+	b := &botHandler{slackClient: c}
+	onCall := func(text string) {
+		w := httptest.NewRecorder()
+		v := url.Values{}
+		v.Set("user_id", "user")
+		v.Set("command", "/devopsbot")
+		v.Set("text", text)
+		b.handleCommand(w, newPostRequest(bytes.NewBufferString(v.Encode())))
+		assert.Equal(t, 200, w.Code)
+	}
+
+	onCall("oncall")
+	assert.Equal(t, "No on-call schedules are configured", c.response["text"][0])
+
+	b.opts.OnCall = newTestSchedules(t)
+	onCall("oncall")
+	text := c.response["text"][0]
+	assert.Contains(t, text, "*database*: <@U2> until <!date^")
+	assert.Contains(t, text, "*platform*: <@U1> until <!date^")
+	assert.Contains(t, text, ", next <@U9> from <!date^")
+
+	onCall("oncall Platform")
+	assert.NotContains(t, c.response["text"][0], "database")
+	assert.Contains(t, c.response["text"][0], "<@U1>")
+
+	onCall("oncall network")
+	assert.Equal(t, `Unknown team "network", the teams with an on-call schedule are database, platform`, c.response["text"][0])
+}
+
+func TestCmdIncidentOnCallResponder(t *testing.T) {
+	c := &dummyClient{
+		User:             &slack.User{Locale: "en-US"},
+		AuthTestResponse: &slack.AuthTestResponse{UserID: "UBOT"},
+		viewResponse:     &slack.ViewResponse{},
+		Channel:          &slack.Channel{},
+		ChannelPages:     [][]slack.Channel{{newTestChannel("CB", "ops-incidents")}},
+	}
+	b := &botHandler{
+		slackClient: c,
+		incidents:   newTestStore(t),
+		opts: Opts{
+			BroadcastChannelID:     "CB",
+			IncidentEnvs:           `["prod"]`,
+			IncidentRegions:        `["eu"]`,
+			IncidentSeverityLevels: `["high"]`,
+			IncidentImpactLevels:   `["high"]`,
+			OnCall:                 newTestSchedules(t),
+			OnCallResponderTeam:    "platform",
+		},
+	}
+	w := httptest.NewRecorder()
+	v := url.Values{}
+	v.Set("user_id", "user")
+	v.Set("command", "/devopsbot")
+	v.Set("text", "incident")
+	v.Set("trigger_id", "trigger")
+	b.handleCommand(w, newPostRequest(bytes.NewBufferString(v.Encode())))
+	assert.Equal(t, 200, w.Code)
+	require.Len(t, c.views, 1)
+
+	blocks, err := json.Marshal(c.views[0].Blocks)
+	require.NoError(t, err)
+	assert.Contains(t, string(blocks), `"action_id":"incident_responder","initial_user":"U1"`)
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/rs/zerolog"
+)
+
+// Action - something that can be restricted to some roles by a PermissionPolicy
+type Action string
+
+// The actions of a PermissionPolicy
+const (
+	ActionDeclare  Action = "declare"
+	ActionResolve  Action = "resolve"
+	ActionArchive  Action = "archive"
+	ActionReopen   Action = "reopen"
+	ActionEscalate Action = "escalate"
+	ActionHandover Action = "handover"
+	// ActionConfig - changing the configuration of the bot
+	ActionConfig Action = "config"
+)
+
+// Role - who may do an action in a PermissionPolicy
+type Role string
+
+// The roles of a PermissionPolicy
+const (
+	// RoleAnyone - anyone who can run the bot commands
+	RoleAnyone Role = "anyone"
+	// RoleAdmin - the members of the admin user group
+	RoleAdmin Role = "admin"
+	// RoleCommander - the commander of the incident
+	RoleCommander Role = "commander"
+	// RoleResponder - the responder of the incident
+	RoleResponder Role = "responder"
+	// RoleDeclarer - the person who declared the incident
+	RoleDeclarer Role = "declarer"
+)
+
+// The descriptions of the actions in refusals
+var actionDescriptions = map[Action]string{
+	ActionDeclare:  "declare incidents",
+	ActionResolve:  "resolve this incident",
+	ActionArchive:  "archive the channel of this incident",
+	ActionReopen:   "reopen this incident",
+	ActionEscalate: "change the severity of this incident",
+	ActionHandover: "hand over the roles of this incident",
+	ActionConfig:   "change the configuration of the bot",
+}
+
+// The descriptions of the roles in refusals
+var roleDescriptions = map[Role]string{
+	RoleAnyone:    "anyone",
+	RoleAdmin:     "admins",
+	RoleCommander: "the incident commander",
+	RoleResponder: "the incident responder",
+	RoleDeclarer:  "the person who declared the incident",
+}
+
+// PermissionPolicy - the roles that may do each action, an action is allowed if the user has any of its roles
+type PermissionPolicy map[Action][]Role
+
+// DefaultPermissionPolicy - the policy for the actions that the configured policy does not mention
+var DefaultPermissionPolicy = PermissionPolicy{
+	ActionDeclare:  {RoleAnyone},
+	ActionResolve:  {RoleAdmin, RoleCommander, RoleDeclarer},
+	ActionArchive:  {RoleAdmin, RoleCommander},
+	ActionReopen:   {RoleAdmin, RoleCommander, RoleDeclarer},
+	ActionEscalate: {RoleAdmin, RoleCommander, RoleResponder},
+	ActionHandover: {RoleAdmin, RoleCommander},
+	ActionConfig:   {RoleAdmin},
+}
+
+// NewPermissionPolicy - a policy from the roles of some actions, the actions that are not given get the
+// roles of DefaultPermissionPolicy
+func NewPermissionPolicy(roles map[string][]string) (PermissionPolicy, error) {
+	policy := PermissionPolicy{}
+	for action, actionRoles := range DefaultPermissionPolicy {
+		policy[action] = actionRoles
+	}
+	for name, roleNames := range roles {
+		action := Action(strings.ToLower(name))
+		if _, ok := actionDescriptions[action]; !ok {
+			return nil, fmt.Errorf("unknown action %q in permission policy", name)
+		}
+		if len(roleNames) == 0 {
+			return nil, fmt.Errorf("no roles for action %q in permission policy", name)
+		}
+		actionRoles := make([]Role, 0, len(roleNames))
+		for _, roleName := range roleNames {
+			role := Role(strings.ToLower(strings.TrimSpace(roleName)))
+			if _, ok := roleDescriptions[role]; !ok {
+				return nil, fmt.Errorf("unknown role %q for action %q in permission policy", roleName, name)
+			}
+			actionRoles = append(actionRoles, role)
+		}
+		policy[action] = actionRoles
+	}
+	return policy, nil
+}
+
+// permissionPolicy - the configured permission policy, or the default one
+func (h *botHandler) permissionPolicy() PermissionPolicy {
+	if h.opts.Permissions == nil {
+		return DefaultPermissionPolicy
+	}
+	return h.opts.Permissions
+}
+
+// hasRole - whether a user has a role, in the given incident for the incident roles
+func (h *botHandler) hasRole(ctx context.Context, userID string, role Role, inc *store.Incident) bool {
+	switch role {
+	case RoleAnyone:
+		return true
+	case RoleAdmin:
+		return h.isAdmin(ctx, userID)
+	}
+	if inc == nil || userID == "" {
+		return false
+	}
+	switch role {
+	case RoleCommander:
+		return inc.Commander == userID
+	case RoleResponder:
+		return inc.Responder == userID
+	case RoleDeclarer:
+		return inc.Declarer == userID
+	}
+	return false
+}
+
+// authorize - check that a user may do an action, on the given incident if any. The refusal to show to
+// the user is returned if not, and the refusal is logged.
+func (h *botHandler) authorize(ctx context.Context, userID string, action Action, inc *store.Incident) (refusal string, ok bool) {
+	roles := h.permissionPolicy()[action]
+	if roles == nil {
+		roles = DefaultPermissionPolicy[action]
+	}
+	for _, role := range roles {
+		if h.hasRole(ctx, userID, role, inc) {
+			return "", true
+		}
+	}
+
+	log := zerolog.Ctx(ctx).Warn().Str("user_id", userID).Str("action", string(action))
+	if inc != nil {
+		log = log.Str("incident_channel", inc.ChannelID)
+	}
+	log.Msg("Permission denied")
+
+	descriptions := make([]string, 0, len(roles))
+	for _, role := range roles {
+		descriptions = append(descriptions, roleDescriptions[role])
+	}
+	sort.Strings(descriptions)
+	return fmt.Sprintf("You are not allowed to %s, only %s may", actionDescriptions[action], strings.Join(descriptions, " or ")), false
+}
@@ -0,0 +1,202 @@
+package bot
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPermissionPolicy(t *testing.T) {
+	policy, err := NewPermissionPolicy(nil)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPermissionPolicy, policy)
+
+	policy, err = NewPermissionPolicy(map[string][]string{"resolve": {"Admin"}, "declare": {"admin", "commander"}})
+	require.NoError(t, err)
+	assert.Equal(t, []Role{RoleAdmin}, policy[ActionResolve])
+	assert.Equal(t, []Role{RoleAdmin, RoleCommander}, policy[ActionDeclare])
+	assert.Equal(t, DefaultPermissionPolicy[ActionArchive], policy[ActionArchive])
+	// the default policy is left alone
+	assert.Equal(t, []Role{RoleAnyone}, DefaultPermissionPolicy[ActionDeclare])
+
+	// config is accepted, although no command needs it yet
+	policy, err = NewPermissionPolicy(map[string][]string{"config": {"admin"}, "handover": {"admin", "declarer"}})
+	require.NoError(t, err)
+	assert.Equal(t, []Role{RoleAdmin}, policy[ActionConfig])
+	assert.Equal(t, []Role{RoleAdmin, RoleDeclarer}, policy[ActionHandover])
+
+	_, err = NewPermissionPolicy(map[string][]string{"delete": {"admin"}})
+	assert.Error(t, err)
+	_, err = NewPermissionPolicy(map[string][]string{"resolve": {"owner"}})
+	assert.Error(t, err)
+	_, err = NewPermissionPolicy(map[string][]string{"resolve": {}})
+	assert.Error(t, err)
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.TODO()
+	inc := &store.Incident{ChannelID: "C1", Declarer: "U1", Commander: "U2", Responder: "U3"}
+	b := &botHandler{
+		slackClient: &dummyClient{members: []string{"UADMIN"}},
+		admins:      &ugMembers{},
+		opts:        Opts{AdminGroupID: "S1"},
+	}
+
+	testdata := []struct {
+		userID  string
+		action  Action
+		inc     *store.Incident
+		allowed bool
+	}{
+		{"U9", ActionDeclare, nil, true},
+		{"U1", ActionResolve, inc, true},
+		{"U2", ActionResolve, inc, true},
+		{"U3", ActionResolve, inc, false},
+		{"UADMIN", ActionResolve, inc, true},
+		{"U1", ActionArchive, inc, false},
+		{"U2", ActionArchive, inc, true},
+		{"U3", ActionEscalate, inc, true},
+		{"U9", ActionReopen, inc, false},
+		{"U2", ActionResolve, nil, false},
+		{"U1", ActionHandover, inc, false},
+		{"U2", ActionHandover, inc, true},
+		{"UADMIN", ActionHandover, inc, true},
+		{"U2", ActionConfig, inc, false},
+		{"UADMIN", ActionConfig, nil, true},
+		{"", ActionResolve, &store.Incident{}, false},
+	}
+	for _, d := range testdata {
+		refusal, ok := b.authorize(ctx, d.userID, d.action, d.inc)
+		assert.Equal(t, d.allowed, ok, "%s %s", d.userID, d.action)
+		if !ok {
+			assert.Contains(t, refusal, actionDescriptions[d.action])
+		}
+	}
+
+	b.opts.Permissions = PermissionPolicy{ActionResolve: {RoleAdmin}}
+	refusal, ok := b.authorize(ctx, "U2", ActionResolve, inc)
+	assert.False(t, ok)
+	assert.Equal(t, "You are not allowed to resolve this incident, only admins may", refusal)
+	// actions missing from the configured policy get the default roles
+	_, ok = b.authorize(ctx, "U2", ActionArchive, inc)
+	assert.True(t, ok)
+}
+
+func TestResolveNotPermitted(t *testing.T) {
+	ctx := context.TODO()
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C1", Status: store.StatusOpen, Declarer: "U1", Commander: "U2"}))
+	b := &botHandler{
+		slackClient: &dummyClient{},
+		incidents:   incidents,
+		admins:      &ugMembers{},
+	}
+	submit := func(userID, archive string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+			Type: slack.InteractionTypeViewSubmission,
+			User: slack.User{ID: userID},
+			View: slack.View{
+				CallbackID:      "resolve_incident",
+				PrivateMetadata: "C1",
+				State: &slack.ViewState{
+					Values: map[string]map[string]slack.BlockAction{
+						"archive_choice": {"archive_choice": {SelectedOption: slack.OptionBlockObject{Value: archive}}},
+					},
+				},
+			},
+		}))
+		return w
+	}
+
+	w := submit("U3", "No")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"resolution":"You are not allowed to resolve this incident`)
+
+	// the declarer may resolve the incident but not archive its channel
+	w = submit("U1", "Yes")
+	assert.Equal(t, 200, w.Code)
+	assert.NotContains(t, w.Body.String(), `"resolution"`)
+	assert.Contains(t, w.Body.String(), `"archive_choice":"You are not allowed to archive the channel of this incident`)
+
+	// a resolved incident can not be resolved again
+	_, err := incidents.Resolve(ctx, "C1", "U2", "Fixed", time.Now())
+	require.NoError(t, err)
+	w = submit("U2", "No")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"resolution":"`+valIncidentNotOpen)
+}
+
+func TestHandoverNotPermitted(t *testing.T) {
+	ctx := context.TODO()
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C1", Status: store.StatusOpen, Declarer: "U1", Commander: "U2"}))
+	b := &botHandler{
+		slackClient: &dummyClient{},
+		incidents:   incidents,
+		admins:      &ugMembers{},
+	}
+	submit := func(callbackID string, values map[string]map[string]slack.BlockAction) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+			Type: slack.InteractionTypeViewSubmission,
+			User: slack.User{ID: "U3"},
+			View: slack.View{
+				CallbackID:      callbackID,
+				PrivateMetadata: "C1",
+				State:           &slack.ViewState{Values: values},
+			},
+		}))
+		return w
+	}
+
+	// someone without a role can not make themselves commander to get the rights of the commander
+	w := submit("handover_incident", map[string]map[string]slack.BlockAction{
+		"incident_role":            {"incident_role": {SelectedOption: slack.OptionBlockObject{Value: roleCommander}}},
+		"incident_new_role_holder": {"incident_new_role_holder": {SelectedUser: "U3"}},
+	})
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"incident_role":"You are not allowed to hand over the roles of this incident`)
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, "U2", inc.Commander)
+
+	w = submit("resolve_incident", map[string]map[string]slack.BlockAction{
+		"archive_choice": {"archive_choice": {SelectedOption: slack.OptionBlockObject{Value: "No"}}},
+	})
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"resolution":"You are not allowed to resolve this incident`)
+}
+
+func TestControlActionNotPermitted(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C1", Status: store.StatusOpen, Commander: "U2"}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+		admins:      &ugMembers{},
+	}
+
+	w := httptest.NewRecorder()
+	b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+		Type:      slack.InteractionTypeBlockActions,
+		User:      slack.User{ID: "U1"},
+		TriggerID: "trigger",
+		Channel:   slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "C1"}}},
+		ActionCallback: slack.ActionCallbacks{
+			BlockActions: []*slack.BlockAction{{BlockID: controlsBlockID, ActionID: actionControlResolve, Value: "C1"}},
+		},
+	}))
+	assert.Equal(t, 200, w.Code)
+	assert.Empty(t, c.views)
+	require.Len(t, c.messages, 1)
+	assert.Contains(t, c.messages[0].Get("text"), "You are not allowed to resolve this incident")
+}
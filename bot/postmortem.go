@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+)
+
+//go:embed postmortem.md.tmpl
+var defaultPostmortemTemplate string
+
+// postmortemFuncs - the functions available in postmortem templates
+var postmortemFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"utc":      func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05") },
+	"duration": formatAge,
+}
+
+// ParsePostmortemTemplate - parse a Go text/template for postmortem documents,
+// the built-in template is used if text is empty
+func ParsePostmortemTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultPostmortemTemplate
+	}
+	tmpl, err := template.New("postmortem").Funcs(postmortemFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse postmortem template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// postmortemData - everything the bot knows about a resolved incident, as seen by postmortem templates
+type postmortemData struct {
+	// Ref - the identifier of the incident like INC-42, empty for incidents recorded before numbering
+	Ref          string
+	ChannelName  string
+	Summary      string
+	Severity     string
+	Impact       string
+	Environments []string
+	Regions      []string
+	Declarer     string
+	Commander    string
+	Responder    string
+	Resolver     string
+	DeclaredAt   time.Time
+	ResolvedAt   time.Time
+	Duration     time.Duration
+	Resolution   string
+	Timeline     []postmortemEvent
+}
+
+type postmortemEvent struct {
+	At    time.Time
+	Actor string
+	Text  string
+}
+
+// renderPostmortem - render the postmortem document of a resolved incident,
+// userName is used to turn Slack user IDs into something readable
+func renderPostmortem(tmpl *template.Template, inc *store.Incident, userName func(userID string) string) (string, error) {
+	data := postmortemData{
+		ChannelName:  inc.ChannelName,
+		Summary:      inc.Summary,
+		Severity:     inc.Severity,
+		Impact:       inc.Impact,
+		Environments: inc.Environments,
+		Regions:      inc.Regions,
+		Declarer:     userName(inc.Declarer),
+		Commander:    userName(inc.Commander),
+		Responder:    userName(inc.Responder),
+		Resolver:     userName(inc.Resolver),
+		DeclaredAt:   inc.DeclaredAt,
+		ResolvedAt:   inc.ResolvedAt,
+		Duration:     inc.ResolvedAt.Sub(inc.DeclaredAt),
+		Resolution:   inc.Resolution,
+	}
+	if inc.Number > 0 {
+		data.Ref = incidentRef(inc.Number)
+	}
+	for _, e := range inc.Timeline {
+		data.Timeline = append(data.Timeline, postmortemEvent{
+			At:    e.At,
+			Actor: userName(e.Actor),
+			Text: userRefRegex.ReplaceAllStringFunc(e.Text, func(ref string) string {
+				return userName(userRefRegex.FindStringSubmatch(ref)[1])
+			}),
+		})
+	}
+	b := &strings.Builder{}
+	if err := tmpl.Execute(b, data); err != nil {
+		return "", fmt.Errorf("failed to render postmortem: %w", err)
+	}
+	return b.String(), nil
+}
+
+// uploadPostmortem - render the postmortem document of a resolved incident and upload it to the incident channel
+func (h *botHandler) uploadPostmortem(ctx context.Context, inc *store.Incident) error {
+	tmpl := h.opts.PostmortemTemplate
+	if tmpl == nil {
+		var err error
+		if tmpl, err = ParsePostmortemTemplate(""); err != nil {
+			return err
+		}
+	}
+	content, err := renderPostmortem(tmpl, inc, h.userNames(ctx))
+	if err != nil {
+		return err
+	}
+	_, err = h.slackClient.UploadFileContext(ctx, slack.FileUploadParameters{
+		Content:        content,
+		Filetype:       "markdown",
+		Filename:       fmt.Sprintf("postmortem-%s.md", inc.ChannelName),
+		Title:          fmt.Sprintf("Postmortem of #%s", inc.ChannelName),
+		InitialComment: "A postmortem document prefilled with what happened, complete it and share it with the team",
+		Channels:       []string{inc.ChannelID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload postmortem: %w", err)
+	}
+	return nil
+}
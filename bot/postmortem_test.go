@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderPostmortem(t *testing.T) {
+	declared := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	inc := &store.Incident{
+		ChannelName:  "inc_db_down",
+		Summary:      "Database is down",
+		Severity:     "high",
+		Impact:       "high",
+		Environments: []string{"Production", "Staging"},
+		Regions:      []string{"eu-west-1"},
+		Declarer:     "U1",
+		Commander:    "U2",
+		Resolver:     "U2",
+		DeclaredAt:   declared,
+		ResolvedAt:   declared.Add(150 * time.Minute),
+		Resolution:   "Failed over to the replica",
+	}
+	inc.AddEvent(declared, "U1", store.EventDeclared, "Declared the incident: Database is down")
+	inc.AddEvent(declared.Add(time.Minute), "U1", store.EventRoleChanged, "Handed over the commander role from <@U1> to <@U2>")
+	names := map[string]string{"U1": "Jane", "U2": "John"}
+	userName := func(userID string) string { return names[userID] }
+
+	tmpl, err := ParsePostmortemTemplate("")
+	require.NoError(t, err)
+	out, err := renderPostmortem(tmpl, inc, userName)
+	require.NoError(t, err)
+	assert.Contains(t, out, "# Postmortem: Database is down\n")
+	assert.Contains(t, out, "| **Environments** | Production, Staging |\n")
+	assert.Contains(t, out, "| **Responder** |  |\n")
+	assert.Contains(t, out, "| **Duration** | 2h 30m |\n")
+	assert.Contains(t, out, "| 2022-07-01 12:01:00 | Jane | Handed over the commander role from Jane to John |\n")
+
+	tmpl, err = ParsePostmortemTemplate("{{.Commander}} resolved {{.ChannelName}} in {{duration .Duration}}")
+	require.NoError(t, err)
+	out, err = renderPostmortem(tmpl, inc, userName)
+	require.NoError(t, err)
+	assert.Equal(t, "John resolved inc_db_down in 2h 30m", out)
+
+	_, err = ParsePostmortemTemplate("{{.Commander")
+	assert.Error(t, err)
+
+	tmpl, err = ParsePostmortemTemplate("{{.DoesNotExist}}")
+	require.NoError(t, err)
+	_, err = renderPostmortem(tmpl, inc, userName)
+	assert.Error(t, err)
+}
+
+func TestDoResolveTasks(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	c.User = &slack.User{RealName: "Jane Doe"}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:   "C1",
+		ChannelName: "inc_db_down",
+		Summary:     "Database is down",
+		Status:      store.StatusOpen,
+		Commander:   "U1",
+		DeclaredAt:  time.Now().Add(-time.Hour),
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+	}
+
+	b.doResolveTasks(ctx, &resolveParams{
+		broadcastChannel:   "B1",
+		incidentChannel:    "C1",
+		incidentResolution: "Failed over to the replica",
+		incidentArchive:    true,
+		incidentResolver:   "U1",
+	})
+
+	require.Len(t, c.uploads, 1)
+	assert.Equal(t, []string{"C1"}, c.uploads[0].Channels)
+	assert.Equal(t, "postmortem-inc_db_down.md", c.uploads[0].Filename)
+	assert.Contains(t, c.uploads[0].Content, "Failed over to the replica")
+	assert.Contains(t, c.uploads[0].Content, "| **Commander** | Jane Doe |")
+
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusResolved, inc.Status)
+	assert.Equal(t, "B1", inc.ResolutionChannelID)
+	assert.NotEmpty(t, inc.ResolutionTS)
+	require.Len(t, inc.Timeline, 2)
+	assert.Equal(t, store.EventArchived, inc.Timeline[1].Kind)
+
+	// resolving again is neither counted nor announced
+	messages := len(c.messages)
+	b.doResolveTasks(ctx, &resolveParams{
+		broadcastChannel:   "B1",
+		incidentChannel:    "C1",
+		incidentResolution: "Failed over again",
+		incidentResolver:   "U1",
+	})
+	assert.Len(t, c.uploads, 1)
+	require.Len(t, c.messages, messages+1)
+	assert.Equal(t, valIncidentNotOpen, c.messages[messages].Get("text"))
+
+	// incidents that were never recorded have no postmortem
+	b.doResolveTasks(ctx, &resolveParams{
+		broadcastChannel: "B1",
+		incidentChannel:  "C2",
+		incidentResolver: "U1",
+	})
+	assert.Len(t, c.uploads, 1)
+}
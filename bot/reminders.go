@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+// defaultReminderInterval - how long the commander may go without posting a progress update if not configured
+const defaultReminderInterval = 30 * time.Minute
+
+// defaultReminderKey - the key of the durations by severity that applies to the severities missing from them
+const defaultReminderKey = "default"
+
+// ParseReminderIntervals - parse the reminder intervals by severity, with durations like "15m" or "1h",
+// the severities are lowercased and "default" applies to the severities missing from them
+func ParseReminderIntervals(intervals map[string]string) (map[string]time.Duration, error) {
+	return parseSeverityDurations("reminder interval", intervals)
+}
+
+// parseSeverityDurations - parse positive durations by lowercase severity, what names them in errors
+func parseSeverityDurations(what string, durations map[string]string) (map[string]time.Duration, error) {
+	parsed := map[string]time.Duration{}
+	for severity, duration := range durations {
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for severity %q: %w", what, severity, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid %s for severity %q: must be positive", what, severity)
+		}
+		parsed[strings.ToLower(severity)] = d
+	}
+	return parsed, nil
+}
+
+// severityDuration - the duration for the severity in durations, the one of defaultKey if the severity is missing
+// from it, or fallback if both are missing
+func severityDuration(durations map[string]time.Duration, defaultKey, severity string, fallback time.Duration) time.Duration {
+	if d, ok := durations[strings.ToLower(severity)]; ok {
+		return d
+	}
+	if d, ok := durations[defaultKey]; ok {
+		return d
+	}
+	return fallback
+}
+
+// reminderInterval - how long the commander of an incident of the given severity may go without posting a progress update
+func (h *botHandler) reminderInterval(severity string) time.Duration {
+	return severityDuration(h.opts.ReminderIntervals, defaultReminderKey, severity, defaultReminderInterval)
+}
+
+// lastProgress - when there was last progress on the incident: its declaration, its latest reopening or its
+// latest progress update
+func lastProgress(inc *store.Incident) time.Time {
+	last := inc.DeclaredAt
+	for _, e := range inc.Timeline {
+		if e.Kind == store.EventReopened && e.At.After(last) {
+			last = e.At
+		}
+	}
+	if len(inc.Updates) > 0 && inc.Updates[len(inc.Updates)-1].At.After(last) {
+		last = inc.Updates[len(inc.Updates)-1].At
+	}
+	return last
+}
+
+// reminderDue - when the commander of the incident is to be reminded next. The first reminder is due when the
+// next update promised in the latest progress update is, or an interval after the last progress,
+// and later reminders an interval after the previous one.
+func (h *botHandler) reminderDue(inc *store.Incident) time.Time {
+	last := lastProgress(inc)
+	interval := h.reminderInterval(inc.Severity)
+	if inc.LastReminderAt.After(last) {
+		return inc.LastReminderAt.Add(interval)
+	}
+	if len(inc.Updates) > 0 {
+		update := inc.Updates[len(inc.Updates)-1]
+		if update.At.Equal(last) && !update.NextUpdateAt.IsZero() {
+			return update.NextUpdateAt
+		}
+	}
+	return last.Add(interval)
+}
+
+// remindCommanders - remind the commanders of open incidents that are due for a progress update to post one
+func (h *botHandler) remindCommanders(ctx context.Context, now time.Time) {
+	log := zerolog.Ctx(ctx)
+	incidents, err := h.incidents.List(ctx, store.ListOptions{Status: store.StatusOpen})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not list open incidents to remind commanders")
+		return
+	}
+	for _, inc := range incidents {
+		if inc.Commander == "" || now.Before(h.reminderDue(inc)) {
+			continue
+		}
+		text := fmt.Sprintf("Reminder for IC <@%s>: Post a progress update about the incident with `/devopsbot update`, the last progress was %s ago",
+			inc.Commander, now.Sub(lastProgress(inc)).Round(time.Minute))
+		if err := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionText(text, false)); err != nil {
+			log.Error().Err(err).Str("incident_channel", inc.ChannelID).Msg("Could not remind commander")
+			continue
+		}
+		if _, err := h.incidents.Update(ctx, inc.ChannelID, func(inc *store.Incident) error {
+			inc.LastReminderAt = now
+			return nil
+		}); err != nil {
+			log.Error().Err(err).Str("incident_channel", inc.ChannelID).Msg("Could not record reminder")
+		}
+	}
+}
+
+// deleteChannelReminders - delete the channel reminders that versions before 0.37.0 added to incidents,
+// since the bot reminds the commanders itself
+func (h *botHandler) deleteChannelReminders(ctx context.Context) {
+	incidents, err := h.incidents.List(ctx, store.ListOptions{})
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Could not list incidents to delete channel reminders")
+		return
+	}
+	for _, inc := range incidents {
+		h.deleteChannelReminder(ctx, inc)
+	}
+}
+
+// deleteChannelReminder - delete the channel reminder that a version before 0.37.0 added to the incident, if any
+func (h *botHandler) deleteChannelReminder(ctx context.Context, inc *store.Incident) {
+	if inc.ReminderID == "" {
+		return
+	}
+	log := zerolog.Ctx(ctx).With().Str("incident_channel", inc.ChannelID).Str("reminder_id", inc.ReminderID).Logger()
+	if h.opts.ReminderClient == nil {
+		log.Warn().Msg("Channel reminder of an earlier version can not be deleted without slack.userAccessToken")
+		return
+	}
+	// The reminder may have been deleted by hand
+	if err := h.opts.ReminderClient.DeleteReminderContext(ctx, inc.ReminderID); err != nil && err.Error() != "not_found" {
+		log.Error().Err(err).Msg("Could not delete channel reminder")
+		return
+	}
+	if _, err := h.incidents.Update(ctx, inc.ChannelID, func(inc *store.Incident) error {
+		inc.ReminderID = ""
+		return nil
+	}); err != nil {
+		log.Error().Err(err).Msg("Could not record deletion of channel reminder")
+	}
+}
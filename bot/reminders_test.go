@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReminderIntervals(t *testing.T) {
+	intervals, err := ParseReminderIntervals(map[string]string{"High": "15m", "default": "1h"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"high": 15 * time.Minute, "default": time.Hour}, intervals)
+
+	_, err = ParseReminderIntervals(map[string]string{"high": "soon"})
+	assert.Error(t, err)
+	_, err = ParseReminderIntervals(map[string]string{"high": "0s"})
+	assert.Error(t, err)
+}
+
+func TestReminderInterval(t *testing.T) {
+	b := &botHandler{}
+	assert.Equal(t, defaultReminderInterval, b.reminderInterval("high"))
+
+	b.opts.ReminderIntervals = map[string]time.Duration{"high": 15 * time.Minute}
+	assert.Equal(t, 15*time.Minute, b.reminderInterval("High"))
+	assert.Equal(t, defaultReminderInterval, b.reminderInterval("low"))
+
+	b.opts.ReminderIntervals["default"] = 2 * time.Hour
+	assert.Equal(t, 2*time.Hour, b.reminderInterval("low"))
+}
+
+func TestReminderDue(t *testing.T) {
+	declared := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	b := &botHandler{}
+
+	inc := &store.Incident{DeclaredAt: declared}
+	assert.Equal(t, declared.Add(30*time.Minute), b.reminderDue(inc))
+
+	// the update promised in the latest progress update is waited for
+	inc.Updates = []store.ProgressUpdate{{At: declared.Add(20 * time.Minute), NextUpdateAt: declared.Add(80 * time.Minute)}}
+	assert.Equal(t, declared.Add(80*time.Minute), b.reminderDue(inc))
+
+	// later reminders come an interval after the previous one
+	inc.LastReminderAt = declared.Add(81 * time.Minute)
+	assert.Equal(t, declared.Add(111*time.Minute), b.reminderDue(inc))
+
+	// reopening counts as progress
+	inc.AddEvent(declared.Add(3*time.Hour), "U1", store.EventReopened, "reopened")
+	assert.Equal(t, declared.Add(210*time.Minute), b.reminderDue(inc))
+}
+
+func TestRemindCommanders(t *testing.T) {
+	ctx := context.TODO()
+	declared := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C1", Status: store.StatusOpen, Severity: "high", Commander: "U1", DeclaredAt: declared}))
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C2", Status: store.StatusOpen, Severity: "low", Commander: "U2", DeclaredAt: declared}))
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C3", Status: store.StatusResolved, Severity: "high", Commander: "U3", DeclaredAt: declared}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+		opts:        Opts{ReminderIntervals: map[string]time.Duration{"high": 15 * time.Minute, "low": time.Hour}},
+	}
+
+	b.remindCommanders(ctx, declared.Add(10*time.Minute))
+	assert.Empty(t, c.messages)
+
+	// only the commander of the open incident that is due is reminded
+	now := declared.Add(20 * time.Minute)
+	b.remindCommanders(ctx, now)
+	require.Len(t, c.messages, 1)
+	assert.Equal(t, "C1", c.messages[0].Get("channel"))
+	assert.Contains(t, c.messages[0].Get("text"), "Reminder for IC <@U1>")
+	assert.Contains(t, c.messages[0].Get("text"), "20m0s ago")
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.True(t, now.Equal(inc.LastReminderAt))
+
+	// the reminder is recorded, so it is not repeated before the next interval
+	b.remindCommanders(ctx, declared.Add(30*time.Minute))
+	assert.Len(t, c.messages, 1)
+	b.remindCommanders(ctx, declared.Add(35*time.Minute))
+	assert.Len(t, c.messages, 2)
+
+	// a handover is followed
+	_, err = incidents.Update(ctx, "C1", func(inc *store.Incident) error {
+		inc.Commander = "U4"
+		return nil
+	})
+	require.NoError(t, err)
+	b.remindCommanders(ctx, declared.Add(50*time.Minute))
+	require.Len(t, c.messages, 3)
+	assert.Contains(t, c.messages[2].Get("text"), "<@U4>")
+}
+
+// dummyReminderClient - records the deleted reminders
+type dummyReminderClient struct {
+	deleted []string
+	err     error
+}
+
+func (c *dummyReminderClient) DeleteReminderContext(ctx context.Context, id string) error {
+	c.deleted = append(c.deleted, id)
+	return c.err
+}
+
+func TestDeleteChannelReminders(t *testing.T) {
+	ctx := context.TODO()
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C1", Status: store.StatusOpen, ReminderID: "Rm1"}))
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C2", Status: store.StatusResolved, ReminderID: "Rm2"}))
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C3", Status: store.StatusOpen}))
+	b := &botHandler{incidents: incidents}
+
+	// without a user access token the reminders are kept
+	b.deleteChannelReminders(ctx)
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, "Rm1", inc.ReminderID)
+
+	// reminders that fail to be deleted are kept for the next try
+	reminders := &dummyReminderClient{err: errors.New("invalid_auth")}
+	b.opts.ReminderClient = reminders
+	b.deleteChannelReminders(ctx)
+	inc, err = incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, "Rm1", inc.ReminderID)
+
+	// reminders deleted by hand are forgotten too
+	reminders.deleted = nil
+	reminders.err = errors.New("not_found")
+	b.deleteChannelReminders(ctx)
+	assert.ElementsMatch(t, []string{"Rm1", "Rm2"}, reminders.deleted)
+	for _, channelID := range []string{"C1", "C2"} {
+		inc, err = incidents.Get(ctx, channelID)
+		require.NoError(t, err)
+		assert.Empty(t, inc.ReminderID)
+	}
+}
@@ -0,0 +1,251 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/internal/wrappedcontext"
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+const (
+	valIncidentNotResolved = "The chosen incident is not resolved"
+
+	// Slack allows at most 100 options in a static select
+	maxReopenableIncidents = 100
+)
+
+// cmdReopenIncident - handler for reopening a resolved incident, optionally the one given as argument
+func (h *botHandler) cmdReopenIncident(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, incident string) error {
+	initialChannelID, err := h.incidentArgChannel(ctx, incident)
+	if errors.Is(err, store.ErrNotFound) {
+		return h.errorResponse(ctx, w, cmd, h.unknownIncidentText(incident), nil)
+	}
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to get incident: %s", err), err)
+	}
+
+	// Archived channels can not be chosen in a conversations select, so offer the resolved incidents instead
+	resolved, err := h.incidents.List(ctx, store.ListOptions{Status: store.StatusResolved})
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to list incidents: %s", err), err)
+	}
+	if len(resolved) == 0 {
+		return h.errorResponse(ctx, w, cmd, h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "NoResolvedIncidents",
+				Other: "There are no resolved incidents to reopen"},
+		}), nil)
+	}
+	// The most recently resolved incidents are the most likely to be reopened
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].ResolvedAt.After(resolved[j].ResolvedAt)
+	})
+	if len(resolved) > maxReopenableIncidents {
+		resolved = resolved[:maxReopenableIncidents]
+	}
+
+	titleText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "ReopenAnIncident",
+				Other: "Reopen an incident"},
+		}), false, false)
+	closeText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Cancel",
+				Other: "Cancel"},
+		}), false, false)
+	submitText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Reopen",
+				Other: "Reopen"},
+		}), false, false)
+
+	contextText := slack.NewTextBlockObject(slack.MarkdownType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "ReopenIncidentDescription",
+				Other: "This will unarchive the incident channel, invite the commander and responder again, and notify about the reopening in the broadcast channel"},
+		}), false, false)
+	contextBlock := slack.NewContextBlock("context", contextText)
+
+	incidentText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Incident",
+				Other: "Incident"},
+		}), false, false)
+	incidentOptions := make([]*slack.OptionBlockObject, 0, len(resolved))
+	var initialOption *slack.OptionBlockObject
+	for _, inc := range resolved {
+		label := "#" + inc.ChannelName
+		if inc.Number > 0 {
+			label = incidentRef(inc.Number) + " " + label
+		}
+		o := slack.NewOptionBlockObject(inc.ChannelID, slack.NewTextBlockObject(slack.PlainTextType, label, false, false), nil)
+		incidentOptions = append(incidentOptions, o)
+		if inc.ChannelID == initialChannelID {
+			initialOption = o
+		}
+	}
+	incidentOption := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, incidentText, "resolved_incident", incidentOptions...)
+	incidentOption.InitialOption = initialOption
+	incidentBlock := slack.NewInputBlock("resolved_incident", incidentText, nil, incidentOption)
+
+	reasonLabel := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Reason",
+				Other: "Reason"},
+		}), false, false)
+	reasonElement := slack.NewPlainTextInputBlockElement(reasonLabel, "reason")
+	reasonElement.MaxLength = 200
+	reasonElement.Multiline = true
+	reasonBlock := slack.NewInputBlock("reason", reasonLabel, nil, reasonElement)
+
+	blocks := slack.Blocks{
+		BlockSet: []slack.Block{
+			contextBlock,
+			incidentBlock,
+			reasonBlock,
+		},
+	}
+
+	var modalVReq slack.ModalViewRequest
+	modalVReq.Type = slack.ViewType("modal")
+	modalVReq.Title = titleText
+	modalVReq.Close = closeText
+	modalVReq.Submit = submitText
+	modalVReq.Blocks = blocks
+	modalVReq.ClearOnClose = true
+	modalVReq.CallbackID = "reopen_incident"
+
+	_, err = h.slackClient.OpenViewContext(ctx, cmd.TriggerID, modalVReq)
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Error opening view: %s", err), err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+type reopenParams struct {
+	// Incident channel ID
+	incidentChannel string
+	// Why the incident is reopened
+	reason string
+	// Who reopened the incident
+	incidentReopener string
+	// Broadcast channel ID the incident was announced in
+	broadcastChannel string
+}
+
+// reopenIncident - handler for reopening a resolved incident
+func (h *botHandler) reopenIncident(ctx context.Context, payload *slack.InteractionCallback, w http.ResponseWriter) error {
+	reopenParams := &reopenParams{
+		incidentChannel:  payload.View.State.Values["resolved_incident"]["resolved_incident"].SelectedOption.Value,
+		reason:           payload.View.State.Values["reason"]["reason"].Value,
+		incidentReopener: payload.User.ID,
+	}
+	inc, err := h.incidents.Get(ctx, reopenParams.incidentChannel)
+	if errors.Is(err, store.ErrNotFound) {
+		return postErrorResponse(ctx, map[string]string{
+			"resolved_incident": valUnknownIncident,
+		}, w)
+	}
+	if err != nil {
+		return err
+	}
+	reopenParams.broadcastChannel = inc.BroadcastChannelID
+	if inc.Status != store.StatusResolved {
+		return postErrorResponse(ctx, map[string]string{
+			"resolved_incident": valIncidentNotResolved,
+		}, w)
+	}
+	if refusal, ok := h.authorize(ctx, payload.User.ID, ActionReopen, inc); !ok {
+		return postErrorResponse(ctx, map[string]string{
+			"resolved_incident": refusal,
+		}, w)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	// Do the rest via goroutine, which outlives the request and can wait for Slack to recover
+	ctx = waitForSlack(wrappedcontext.WrapContextValues(context.Background(), ctx))
+	go h.doReopenTasks(ctx, reopenParams)
+
+	return nil
+}
+
+// doReopenTasks - bring a resolved incident and its channel back, and announce it
+func (h *botHandler) doReopenTasks(ctx context.Context, params *reopenParams) {
+	log := zerolog.Ctx(ctx)
+	const sendError = "Could not send failure message"
+	if err := h.slackClient.UnArchiveConversationContext(ctx, params.incidentChannel); err != nil && err.Error() != "not_archived" {
+		log.Error().Err(err).Msg("Could not unarchive incident channel")
+		if sendErr := h.sendMessage(ctx, params.broadcastChannel, slack.MsgOptionPostEphemeral(params.incidentReopener),
+			slack.MsgOptionText(fmt.Sprintf("Failed to unarchive incident channel: %s", err.Error()), false)); sendErr != nil {
+			log.Error().Err(sendErr).Msg(sendError)
+		}
+		return
+	}
+	inc, err := h.incidents.Reopen(ctx, params.incidentChannel, params.incidentReopener, params.reason, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Could not record reopening of incident")
+		return
+	}
+	h.opts.Metrics.IncidentReopened()
+
+	// Bring back the people who had a role in the incident
+	invitees := []string{}
+	for _, u := range []string{inc.Commander, inc.Responder, inc.Declarer, params.incidentReopener} {
+		if u != "" {
+			invitees = appendUnique(invitees, u)
+		}
+	}
+	if _, err := h.slackClient.InviteUsersToConversationContext(ctx, inc.ChannelID, invitees...); err != nil {
+		if err.Error() != alreadyInChannel {
+			if sendErr := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionPostEphemeral(params.incidentReopener),
+				slack.MsgOptionText(fmt.Sprintf("Failed to invite people to incident channel: %s", err.Error()), false)); sendErr != nil {
+				log.Error().Err(sendErr).Msg(sendError)
+			}
+		}
+	}
+	if err := h.setIncidentOverview(ctx, inc); err != nil {
+		if sendErr := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionPostEphemeral(params.incidentReopener),
+			slack.MsgOptionText(fmt.Sprintf("Failed to update incident channel: %s", err.Error()), false)); sendErr != nil {
+			log.Error().Err(sendErr).Msg(sendError)
+		}
+	}
+
+	text := fmt.Sprintf(":recycle: The incident %s has been reopened by <@%s>\n"+
+		"*Reason:* %s",
+		incidentLabel(inc.Number, inc.ChannelID), params.incidentReopener, params.reason)
+	if inc.ResolutionTS != "" {
+		permalink, err := h.slackClient.GetPermalinkContext(ctx, &slack.PermalinkParameters{
+			Channel: inc.ResolutionChannelID,
+			Ts:      inc.ResolutionTS,
+		})
+		if err != nil {
+			log.Warn().Err(err).Msg("Could not get link to resolution message")
+		} else {
+			text += fmt.Sprintf("\n<%s|Previous resolution>", permalink)
+		}
+	}
+	if err := h.sendMessage(ctx, inc.BroadcastChannelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Msg("Could not send reopening to broadcast channel")
+	}
+	if err := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Msg("Could not send reopening to incident channel")
+	}
+}
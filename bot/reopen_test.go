@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReopenIncident(t *testing.T) {
+	ctx := context.TODO()
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID: "C1",
+		Status:    store.StatusOpen,
+	}))
+	b := &botHandler{
+		slackClient: &dummyClient{},
+		incidents:   incidents,
+	}
+
+	// open incidents can not be reopened
+	w := httptest.NewRecorder()
+	b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+		Type: slack.InteractionTypeViewSubmission,
+		View: slack.View{
+			CallbackID: "reopen_incident",
+			State: &slack.ViewState{
+				Values: map[string]map[string]slack.BlockAction{
+					"resolved_incident": {"resolved_incident": {SelectedOption: slack.OptionBlockObject{Value: "C1"}}},
+					"reason":            {"reason": {Value: "It is down again"}},
+				},
+			},
+		},
+	}))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), valIncidentNotResolved)
+}
+
+func TestDoReopenTasks(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	c.User = &slack.User{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:           "C1",
+		Number:              3,
+		BroadcastChannelID:  "B1",
+		Status:              store.StatusResolved,
+		Declarer:            "U1",
+		Commander:           "U2",
+		Responder:           "U2",
+		ResolvedAt:          time.Now(),
+		Resolution:          "Restarted the service",
+		ResolutionChannelID: "B1",
+		ResolutionTS:        "1656676800.000100",
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+	}
+
+	b.doReopenTasks(ctx, &reopenParams{
+		incidentChannel:  "C1",
+		reason:           "It is down again",
+		incidentReopener: "U3",
+	})
+
+	assert.Equal(t, []string{"C1"}, c.unarchived)
+	assert.Equal(t, []string{"U2", "U1", "U3"}, c.invited)
+
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, store.StatusOpen, inc.Status)
+	assert.Empty(t, inc.Resolution)
+	require.NotEmpty(t, inc.Timeline)
+	assert.Equal(t, store.EventReopened, inc.Timeline[len(inc.Timeline)-1].Kind)
+
+	require.Len(t, c.messages, 2)
+	assert.Equal(t, "B1", c.messages[0].Get("channel"))
+	assert.Equal(t, "C1", c.messages[1].Get("channel"))
+	text := c.messages[0].Get("text")
+	assert.Contains(t, text, "INC-3 <#C1> has been reopened by <@U3>")
+	assert.Contains(t, text, "https://example.slack.com/archives/B1/p1656676800000100")
+
+	// a failure to unarchive is told in the broadcast channel of the incident
+	c.messages = nil
+	c.err = errors.New("restricted_action")
+	b.doReopenTasks(ctx, &reopenParams{
+		incidentChannel:  "C1",
+		reason:           "It is down again",
+		incidentReopener: "U3",
+		broadcastChannel: "B1",
+	})
+	require.Len(t, c.messages, 1)
+	assert.Equal(t, "B1", c.messages[0].Get("channel"))
+	assert.Equal(t, "Failed to unarchive incident channel: restricted_action", c.messages[0].Get("text"))
+}
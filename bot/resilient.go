@@ -0,0 +1,457 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+// ErrSlackUnavailable - Slack has failed too often lately, so calls are not attempted for a while
+var ErrSlackUnavailable = errors.New("the Slack API is not responding at the moment, try again in a minute")
+
+// triggerTimeout - how long opening a view may take including retries, trigger IDs expire after three seconds
+const triggerTimeout = 2 * time.Second
+
+type waitForSlackKey struct{}
+
+// waitForSlack - mark ctx as the context of work in the background, whose Slack calls wait for the circuit
+// breaker to close once instead of failing with ErrSlackUnavailable, so that the work is not lost
+func waitForSlack(ctx context.Context) context.Context {
+	return context.WithValue(ctx, waitForSlackKey{}, true)
+}
+
+// resilienceOpts - how hard the resilient client tries
+type resilienceOpts struct {
+	// maxAttempts - how many times a call is attempted at most
+	maxAttempts int
+	// baseBackoff - the wait before the first retry of a failed call, doubled for every retry
+	baseBackoff time.Duration
+	// maxBackoff - the longest wait between retries of a failed call
+	maxBackoff time.Duration
+	// maxRetryAfter - the longest Retry-After of a rate limited call that is waited for
+	maxRetryAfter time.Duration
+	// channelPostInterval - the shortest time between two posts in the same channel
+	channelPostInterval time.Duration
+	// breakerThreshold - how many failures in a row open the circuit breaker
+	breakerThreshold int
+	// breakerCooldown - how long the circuit breaker stays open
+	breakerCooldown time.Duration
+}
+
+// Slack allows about one message per second in a channel: https://api.slack.com/docs/rate-limits
+var defaultResilienceOpts = resilienceOpts{
+	maxAttempts:         4,
+	baseBackoff:         500 * time.Millisecond,
+	maxBackoff:          8 * time.Second,
+	maxRetryAfter:       time.Minute,
+	channelPostInterval: time.Second,
+	breakerThreshold:    5,
+	breakerCooldown:     30 * time.Second,
+}
+
+// resilientSlackClient - a SlackClient that retries failed calls to the wrapped client
+type resilientSlackClient struct {
+	client   SlackClient
+	opts     resilienceOpts
+	breaker  *circuitBreaker
+	channels *channelGate
+	// sleep - wait for d or until ctx is done, replaceable in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewResilientSlackClient - wrap a SlackClient so that rate limited calls are retried after the
+// Retry-After that Slack asks for, idempotent calls are retried with jittered backoff on transient
+// errors, posts in a channel are spaced out, and calls fail fast with ErrSlackUnavailable while
+// Slack keeps failing
+func NewResilientSlackClient(client SlackClient) SlackClient {
+	return newResilientSlackClient(client, defaultResilienceOpts)
+}
+
+func newResilientSlackClient(client SlackClient, opts resilienceOpts) *resilientSlackClient {
+	return &resilientSlackClient{
+		client: client,
+		opts:   opts,
+		breaker: &circuitBreaker{
+			threshold: opts.breakerThreshold,
+			cooldown:  opts.breakerCooldown,
+			now:       time.Now,
+		},
+		channels: &channelGate{interval: opts.channelPostInterval, slots: map[string]*channelSlot{}},
+		sleep:    sleepContext,
+	}
+}
+
+// sleepContext - wait for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isTransient - whether a call failed in a way that may not happen again, as opposed to Slack refusing it
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr slack.StatusCodeError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500
+	}
+	var slackErr slack.SlackErrorResponse
+	if errors.As(err, &slackErr) {
+		err = errors.New(slackErr.Err)
+	}
+	switch err.Error() {
+	case "internal_error", "fatal_error", "request_timeout", "service_unavailable":
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoff - the jittered wait before the given retry, counting from 1
+func (c *resilientSlackClient) backoff(retry int) time.Duration {
+	d := c.opts.baseBackoff << (retry - 1)
+	if d <= 0 || d > c.opts.maxBackoff {
+		d = c.opts.maxBackoff
+	}
+	// Full jitter, so that retries of calls that failed together are spread out
+	//nolint:gosec
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// call - call fn until it succeeds or fails for good. Rate limited calls are always retried since Slack did
+// not process them, transient failures only if idempotent is set since the call may have had an effect.
+// Calls are not retried when the wait would go past the deadline of ctx.
+func (c *resilientSlackClient) call(ctx context.Context, method string, idempotent bool, fn func() error) error {
+	log := zerolog.Ctx(ctx)
+	waited := false
+	var err error
+	for attempt := 1; attempt <= c.opts.maxAttempts; attempt++ {
+		if !c.breaker.allow() {
+			if waited || !c.waitForBreaker(ctx, method) {
+				return ErrSlackUnavailable
+			}
+			waited = true
+		}
+		err = fn()
+		if err == nil {
+			c.breaker.success()
+			return nil
+		}
+		if attempt == c.opts.maxAttempts {
+			break
+		}
+
+		var wait time.Duration
+		var rateLimitedErr *slack.RateLimitedError
+		switch {
+		case errors.As(err, &rateLimitedErr):
+			if rateLimitedErr.RetryAfter > c.opts.maxRetryAfter {
+				return err
+			}
+			wait = rateLimitedErr.RetryAfter
+		case isTransient(ctx, err):
+			c.breaker.failure()
+			if !idempotent {
+				return err
+			}
+			wait = c.backoff(attempt)
+		default:
+			// Slack refused the call, trying again will not change that
+			c.breaker.success()
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return err
+		}
+		log.Debug().Err(err).Str("slack_method", method).Int("attempt", attempt).Dur("wait", wait).Msg("Retrying Slack API call")
+		if serr := c.sleep(ctx, wait); serr != nil {
+			return err
+		}
+	}
+	if isTransient(ctx, err) {
+		c.breaker.failure()
+	}
+	return err
+}
+
+// waitForBreaker - wait until the circuit breaker closes if ctx is marked with waitForSlack,
+// returns whether calls are allowed again
+func (c *resilientSlackClient) waitForBreaker(ctx context.Context, method string) bool {
+	if wait, _ := ctx.Value(waitForSlackKey{}).(bool); !wait {
+		return false
+	}
+	cooldown := c.breaker.remaining()
+	zerolog.Ctx(ctx).Debug().Str("slack_method", method).Dur("wait", cooldown).Msg("Waiting for Slack API to recover")
+	if err := c.sleep(ctx, cooldown); err != nil {
+		return false
+	}
+	return c.breaker.allow()
+}
+
+// circuitBreaker - stops calls for a while after too many failures in a row
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+// allow - whether a call may be attempted, after the cooldown calls are let through again
+// and the breaker opens again at the first failure
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.now().Before(b.openUntil)
+}
+
+// remaining - how long the breaker stays open
+func (b *circuitBreaker) remaining() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if d := b.openUntil.Sub(b.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// channelGate - lets one post at a time through to a channel, spaced out by interval
+type channelGate struct {
+	mu       sync.Mutex
+	interval time.Duration
+	slots    map[string]*channelSlot
+}
+
+type channelSlot struct {
+	// turn - holds a value while a post to the channel is in progress
+	turn chan struct{}
+	// last - when the last post to the channel was done
+	last time.Time
+}
+
+// acquire - wait for the turn to post to a channel, the returned function must be called after posting
+func (g *channelGate) acquire(ctx context.Context, channelID string, sleep func(context.Context, time.Duration) error) (func(), error) {
+	g.mu.Lock()
+	slot, ok := g.slots[channelID]
+	if !ok {
+		slot = &channelSlot{turn: make(chan struct{}, 1)}
+		g.slots[channelID] = slot
+	}
+	g.mu.Unlock()
+
+	select {
+	case slot.turn <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if wait := g.interval - time.Since(slot.last); wait > 0 {
+		if err := sleep(ctx, wait); err != nil {
+			<-slot.turn
+			return nil, err
+		}
+	}
+	return func() {
+		slot.last = time.Now()
+		<-slot.turn
+	}, nil
+}
+
+// post - call fn as a post to a channel, waiting for the turn of the channel first
+func (c *resilientSlackClient) post(ctx context.Context, method, channelID string, idempotent bool, fn func() error) error {
+	release, err := c.channels.acquire(ctx, channelID, c.sleep)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.call(ctx, method, idempotent, fn)
+}
+
+// Posting a message is not idempotent, a message could be posted twice
+func (c *resilientSlackClient) SendMessageContext(ctx context.Context, channelID string, options ...slack.MsgOption) (respChannel, respTimestamp, respText string, err error) {
+	err = c.post(ctx, "SendMessage", channelID, false, func() error {
+		var err error
+		respChannel, respTimestamp, respText, err = c.client.SendMessageContext(ctx, channelID, options...)
+		return err
+	})
+	return respChannel, respTimestamp, respText, err
+}
+
+func (c *resilientSlackClient) UpdateMessageContext(ctx context.Context, channelID, timestamp string, options ...slack.MsgOption) (respChannel, respTimestamp, respText string, err error) {
+	err = c.post(ctx, "UpdateMessage", channelID, true, func() error {
+		var err error
+		respChannel, respTimestamp, respText, err = c.client.UpdateMessageContext(ctx, channelID, timestamp, options...)
+		return err
+	})
+	return respChannel, respTimestamp, respText, err
+}
+
+func (c *resilientSlackClient) GetUserGroupMembersContext(ctx context.Context, userGroup string) (members []string, err error) {
+	err = c.call(ctx, "GetUserGroupMembers", true, func() error {
+		var err error
+		members, err = c.client.GetUserGroupMembersContext(ctx, userGroup)
+		return err
+	})
+	return members, err
+}
+
+// Trigger IDs expire after three seconds, so only views rate limited for a short while are opened again
+func (c *resilientSlackClient) OpenViewContext(ctx context.Context, triggerID string, view slack.ModalViewRequest) (resp *slack.ViewResponse, err error) {
+	ctx, cancel := context.WithTimeout(ctx, triggerTimeout)
+	defer cancel()
+	err = c.call(ctx, "OpenView", false, func() error {
+		var err error
+		resp, err = c.client.OpenViewContext(ctx, triggerID, view)
+		return err
+	})
+	return resp, err
+}
+
+func (c *resilientSlackClient) UpdateViewContext(ctx context.Context, view slack.ModalViewRequest, externalID, hash, viewID string) (resp *slack.ViewResponse, err error) {
+	err = c.call(ctx, "UpdateView", true, func() error {
+		var err error
+		resp, err = c.client.UpdateViewContext(ctx, view, externalID, hash, viewID)
+		return err
+	})
+	return resp, err
+}
+
+func (c *resilientSlackClient) CreateConversationContext(ctx context.Context, channelName string, isPrivate bool) (channel *slack.Channel, err error) {
+	err = c.call(ctx, "CreateConversation", false, func() error {
+		var err error
+		channel, err = c.client.CreateConversationContext(ctx, channelName, isPrivate)
+		return err
+	})
+	return channel, err
+}
+
+func (c *resilientSlackClient) GetConversationInfoContext(ctx context.Context, channelID string, includeLocale bool) (channel *slack.Channel, err error) {
+	err = c.call(ctx, "GetConversationInfo", true, func() error {
+		var err error
+		channel, err = c.client.GetConversationInfoContext(ctx, channelID, includeLocale)
+		return err
+	})
+	return channel, err
+}
+
+// Archiving again fails with already_archived, which the callers do not expect
+func (c *resilientSlackClient) ArchiveConversationContext(ctx context.Context, channelID string) error {
+	return c.call(ctx, "ArchiveConversation", false, func() error {
+		return c.client.ArchiveConversationContext(ctx, channelID)
+	})
+}
+
+func (c *resilientSlackClient) UnArchiveConversationContext(ctx context.Context, channelID string) error {
+	return c.call(ctx, "UnArchiveConversation", true, func() error {
+		return c.client.UnArchiveConversationContext(ctx, channelID)
+	})
+}
+
+func (c *resilientSlackClient) SetPurposeOfConversationContext(ctx context.Context, channelID, purpose string) (channel *slack.Channel, err error) {
+	err = c.call(ctx, "SetPurposeOfConversation", true, func() error {
+		var err error
+		channel, err = c.client.SetPurposeOfConversationContext(ctx, channelID, purpose)
+		return err
+	})
+	return channel, err
+}
+
+func (c *resilientSlackClient) SetTopicOfConversationContext(ctx context.Context, channelID, topic string) (channel *slack.Channel, err error) {
+	err = c.call(ctx, "SetTopicOfConversation", true, func() error {
+		var err error
+		channel, err = c.client.SetTopicOfConversationContext(ctx, channelID, topic)
+		return err
+	})
+	return channel, err
+}
+
+func (c *resilientSlackClient) InviteUsersToConversationContext(ctx context.Context, channelID string, users ...string) (channel *slack.Channel, err error) {
+	err = c.call(ctx, "InviteUsersToConversation", true, func() error {
+		var err error
+		channel, err = c.client.InviteUsersToConversationContext(ctx, channelID, users...)
+		return err
+	})
+	return channel, err
+}
+
+func (c *resilientSlackClient) GetUserInfoContext(ctx context.Context, user string) (info *slack.User, err error) {
+	err = c.call(ctx, "GetUserInfo", true, func() error {
+		var err error
+		info, err = c.client.GetUserInfoContext(ctx, user)
+		return err
+	})
+	return info, err
+}
+
+func (c *resilientSlackClient) AuthTestContext(ctx context.Context) (resp *slack.AuthTestResponse, err error) {
+	err = c.call(ctx, "AuthTest", true, func() error {
+		var err error
+		resp, err = c.client.AuthTestContext(ctx)
+		return err
+	})
+	return resp, err
+}
+
+func (c *resilientSlackClient) GetConversationsForUserContext(ctx context.Context, params *slack.GetConversationsForUserParameters) (channels []slack.Channel, cursor string, err error) {
+	err = c.call(ctx, "GetConversationsForUser", true, func() error {
+		var err error
+		channels, cursor, err = c.client.GetConversationsForUserContext(ctx, params)
+		return err
+	})
+	return channels, cursor, err
+}
+
+func (c *resilientSlackClient) GetUsersInConversationContext(ctx context.Context, params *slack.GetUsersInConversationParameters) (members []string, cursor string, err error) {
+	err = c.call(ctx, "GetUsersInConversation", true, func() error {
+		var err error
+		members, cursor, err = c.client.GetUsersInConversationContext(ctx, params)
+		return err
+	})
+	return members, cursor, err
+}
+
+func (c *resilientSlackClient) UploadFileContext(ctx context.Context, params slack.FileUploadParameters) (file *slack.File, err error) {
+	err = c.call(ctx, "UploadFile", false, func() error {
+		var err error
+		file, err = c.client.UploadFileContext(ctx, params)
+		return err
+	})
+	return file, err
+}
+
+func (c *resilientSlackClient) GetPermalinkContext(ctx context.Context, params *slack.PermalinkParameters) (permalink string, err error) {
+	err = c.call(ctx, "GetPermalink", true, func() error {
+		var err error
+		permalink, err = c.client.GetPermalinkContext(ctx, params)
+		return err
+	})
+	return permalink, err
+}
@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyClient - a dummyClient that fails the next calls with the given errors
+type flakyClient struct {
+	*dummyClient
+	errs  []error
+	calls int
+}
+
+func (c *flakyClient) nextErr() error {
+	c.calls++
+	if len(c.errs) == 0 {
+		return nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return err
+}
+
+func (c *flakyClient) SendMessageContext(ctx context.Context, channelID string, options ...slack.MsgOption) (string, string, string, error) {
+	if err := c.nextErr(); err != nil {
+		return "", "", "", err
+	}
+	return c.dummyClient.SendMessageContext(ctx, channelID, options...)
+}
+
+func (c *flakyClient) SetTopicOfConversationContext(ctx context.Context, channelID, topic string) (*slack.Channel, error) {
+	if err := c.nextErr(); err != nil {
+		return nil, err
+	}
+	return c.dummyClient.SetTopicOfConversationContext(ctx, channelID, topic)
+}
+
+func (c *flakyClient) OpenViewContext(ctx context.Context, triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error) {
+	if err := c.nextErr(); err != nil {
+		return nil, err
+	}
+	return c.dummyClient.OpenViewContext(ctx, triggerID, view)
+}
+
+func newTestResilientClient(c SlackClient) (*resilientSlackClient, *[]time.Duration) {
+	r := newResilientSlackClient(c, resilienceOpts{
+		maxAttempts:         3,
+		baseBackoff:         time.Second,
+		maxBackoff:          4 * time.Second,
+		maxRetryAfter:       time.Minute,
+		channelPostInterval: time.Second,
+		breakerThreshold:    3,
+		breakerCooldown:     time.Minute,
+	})
+	sleeps := []time.Duration{}
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return r, &sleeps
+}
+
+func TestResilientClientRetries(t *testing.T) {
+	ctx := context.TODO()
+	unavailable := slack.StatusCodeError{Code: 503, Status: "Service Unavailable"}
+
+	// rate limited posts are retried after the wait Slack asks for
+	c := &flakyClient{dummyClient: &dummyClient{}, errs: []error{&slack.RateLimitedError{RetryAfter: 5 * time.Second}}}
+	r, sleeps := newTestResilientClient(c)
+	_, _, _, err := r.SendMessageContext(ctx, "C1", slack.MsgOptionText("hello", false))
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.calls)
+	assert.Equal(t, []time.Duration{5 * time.Second}, *sleeps)
+	assert.Len(t, c.messages, 1)
+
+	// idempotent calls are retried with backoff on transient errors, until the attempts run out
+	c = &flakyClient{dummyClient: &dummyClient{}, errs: []error{unavailable, unavailable, unavailable}}
+	r, sleeps = newTestResilientClient(c)
+	_, err = r.SetTopicOfConversationContext(ctx, "C1", "topic")
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 3, c.calls)
+	require.Len(t, *sleeps, 2)
+	assert.LessOrEqual(t, (*sleeps)[0], time.Second)
+	assert.LessOrEqual(t, (*sleeps)[1], 2*time.Second)
+
+	// posts are not retried on transient errors, since they could be posted twice
+	c = &flakyClient{dummyClient: &dummyClient{}, errs: []error{unavailable}}
+	r, _ = newTestResilientClient(c)
+	_, _, _, err = r.SendMessageContext(ctx, "C1", slack.MsgOptionText("hello", false))
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 1, c.calls)
+
+	// calls that Slack refuses are not retried
+	c = &flakyClient{dummyClient: &dummyClient{}, errs: []error{errors.New("channel_not_found")}}
+	r, _ = newTestResilientClient(c)
+	_, err = r.SetTopicOfConversationContext(ctx, "C1", "topic")
+	assert.EqualError(t, err, "channel_not_found")
+	assert.Equal(t, 1, c.calls)
+
+	// views are only opened again if that can be done before the trigger expires
+	c = &flakyClient{dummyClient: &dummyClient{}, errs: []error{&slack.RateLimitedError{RetryAfter: time.Second}}}
+	r, _ = newTestResilientClient(c)
+	_, err = r.OpenViewContext(ctx, "trigger", slack.ModalViewRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.calls)
+	c = &flakyClient{dummyClient: &dummyClient{}, errs: []error{&slack.RateLimitedError{RetryAfter: 5 * time.Second}}}
+	r, sleeps = newTestResilientClient(c)
+	_, err = r.OpenViewContext(ctx, "trigger", slack.ModalViewRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, c.calls)
+	assert.Empty(t, *sleeps)
+}
+
+func TestResilientClientCircuitBreaker(t *testing.T) {
+	ctx := context.TODO()
+	unavailable := slack.StatusCodeError{Code: 502, Status: "Bad Gateway"}
+	c := &flakyClient{dummyClient: &dummyClient{}, errs: []error{unavailable, unavailable, unavailable}}
+	r, _ := newTestResilientClient(c)
+	now := time.Now()
+	r.breaker.now = func() time.Time { return now }
+
+	_, err := r.SetTopicOfConversationContext(ctx, "C1", "topic")
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 3, c.calls)
+
+	// the breaker is open, so Slack is not called
+	_, err = r.SetTopicOfConversationContext(ctx, "C1", "topic")
+	assert.ErrorIs(t, err, ErrSlackUnavailable)
+	assert.Equal(t, 3, c.calls)
+
+	// after the cooldown calls go through again
+	now = now.Add(time.Minute)
+	_, err = r.SetTopicOfConversationContext(ctx, "C1", "topic")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, c.calls)
+
+	// work in the background waits for the breaker to close instead
+	c.errs = []error{unavailable, unavailable, unavailable}
+	_, err = r.SetTopicOfConversationContext(ctx, "C1", "topic")
+	assert.Equal(t, unavailable, err)
+	sleeps := []time.Duration{}
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return nil
+	}
+	_, err = r.SetTopicOfConversationContext(waitForSlack(ctx), "C1", "topic")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Minute}, sleeps)
+	assert.Equal(t, 8, c.calls)
+}
+
+func TestResilientClientChannelPosts(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	r, sleeps := newTestResilientClient(c)
+
+	_, _, _, err := r.SendMessageContext(ctx, "C1", slack.MsgOptionText("one", false))
+	require.NoError(t, err)
+	_, _, _, err = r.SendMessageContext(ctx, "C2", slack.MsgOptionText("two", false))
+	require.NoError(t, err)
+	assert.Empty(t, *sleeps)
+
+	// the second post in a channel waits for the post interval
+	_, _, _, err = r.SendMessageContext(ctx, "C1", slack.MsgOptionText("three", false))
+	require.NoError(t, err)
+	require.Len(t, *sleeps, 1)
+	assert.Greater(t, (*sleeps)[0], time.Duration(0))
+	assert.LessOrEqual(t, (*sleeps)[0], time.Second)
+	assert.Len(t, c.messages, 3)
+}
+
+func TestHandleCommandSlackUnavailable(t *testing.T) {
+	b := &botHandler{
+		slackClient: &dummyClient{err: ErrSlackUnavailable},
+	}
+	v := url.Values{}
+	v.Set("user_id", "user")
+	v.Set("command", "/devopsbot")
+	v.Set("text", "status")
+	w := httptest.NewRecorder()
+	b.handleCommand(w, newPostRequest(bytes.NewBufferString(v.Encode())))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, ErrSlackUnavailable.Error(), w.Body.String())
+}
+
+func TestSlackUnavailableResponse(t *testing.T) {
+	ctx := context.TODO()
+	payload := &slack.InteractionCallback{View: slack.View{Blocks: slack.Blocks{BlockSet: []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "text", false, false), nil, nil),
+		slack.NewInputBlock("reason", slack.NewTextBlockObject(slack.PlainTextType, "Reason", false, false), nil,
+			slack.NewPlainTextInputBlockElement(nil, "reason")),
+	}}}}
+
+	w := httptest.NewRecorder()
+	assert.False(t, slackUnavailableResponse(ctx, payload, errors.New("channel_not_found"), w))
+	assert.Empty(t, w.Body.String())
+
+	// the error is shown in the modal, so that it can be submitted again later
+	assert.True(t, slackUnavailableResponse(ctx, payload, fmt.Errorf("failed to get user info: %w", ErrSlackUnavailable), w))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"reason":"`+ErrSlackUnavailable.Error()+`"`)
+}
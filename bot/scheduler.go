@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// schedulerTick - how often the scheduled tasks run
+const schedulerTick = time.Minute
+
+// scheduledTask - work that is done on every tick of the scheduler, given the time of the tick. Tasks keep what
+// they need to remember in the incident store, so that nothing is lost when the bot restarts.
+type scheduledTask func(ctx context.Context, now time.Time)
+
+// scheduler - runs tasks one after the other on every tick
+type scheduler struct {
+	tick  time.Duration
+	tasks []scheduledTask
+	now   func() time.Time
+}
+
+func newScheduler(tasks ...scheduledTask) *scheduler {
+	return &scheduler{tick: schedulerTick, tasks: tasks, now: time.Now}
+}
+
+// scheduledTasks - the tasks of the bot that run on every tick of the scheduler
+func (h *botHandler) scheduledTasks() []scheduledTask {
+	return []scheduledTask{h.remindCommanders, h.detectStaleIncidents, h.escalateAcknowledgements}
+}
+
+// run - run the tasks now and then on every tick until ctx is done
+func (s *scheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	for {
+		s.runTasks(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runTasks - run every task once, a task that panics does not stop the others
+func (s *scheduler) runTasks(ctx context.Context) {
+	now := s.now()
+	for _, task := range s.tasks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					zerolog.Ctx(ctx).Error().Err(fmt.Errorf("%v", r)).Msg("Scheduled task panicked")
+				}
+			}()
+			task(ctx, now)
+		}()
+	}
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	ticks := make(chan time.Time, 10)
+	s := newScheduler(
+		func(ctx context.Context, now time.Time) {
+			panic("broken task")
+		},
+		func(ctx context.Context, now time.Time) {
+			ticks <- now
+		},
+	)
+	s.tick = time.Millisecond
+	s.now = func() time.Time { return now }
+
+	done := make(chan struct{})
+	go func() {
+		s.run(ctx)
+		close(done)
+	}()
+
+	// the tasks run right away and then on every tick, even if another task panics
+	for i := 0; i < 2; i++ {
+		select {
+		case at := <-ticks:
+			assert.Equal(t, now, at)
+		case <-time.After(5 * time.Second):
+			t.Fatal("task did not run")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not stop")
+	}
+}
@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// SocketModeRunner - receives slash commands, interactions and events over a Socket Mode WebSocket opened by the
+// bot, so that Slack does not need to reach the bot over the Internet
+type SocketModeRunner struct {
+	client  *socketmode.Client
+	handler http.Handler
+}
+
+// NewSocketModeRunner - create a runner that handles what client receives like the endpoints of NewBot do,
+// and keeps the admin user group up to date and reminds commanders until ctx is done. The client needs an app-level token.
+func NewSocketModeRunner(ctx context.Context, client *socketmode.Client, slackClient SlackClient, opts Opts) *SocketModeRunner {
+	return newSocketModeRunner(client, newBotHandler(ctx, slackClient, opts))
+}
+
+func newSocketModeRunner(client *socketmode.Client, h *botHandler) *SocketModeRunner {
+	// The requests come over a connection authenticated with the app-level token, so they are not signed
+	return &SocketModeRunner{client: client, handler: h.routes()}
+}
+
+// Run - connect to Slack and handle what is received until ctx is done, reconnecting when Slack asks for it.
+// An error is returned if the connection can not be made again.
+func (r *SocketModeRunner) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- r.client.RunContext(ctx)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return fmt.Errorf("socket mode connection failed: %w", err)
+		case evt := <-r.client.Events:
+			r.handleEvent(ctx, evt)
+		}
+	}
+}
+
+// handleEvent - handle an event of the Socket Mode client, the requests from Slack are handled concurrently
+func (r *SocketModeRunner) handleEvent(ctx context.Context, evt socketmode.Event) {
+	log := zerolog.Ctx(ctx)
+	switch evt.Type {
+	case socketmode.EventTypeConnecting:
+		log.Info().Msg("Connecting to Slack with Socket Mode")
+	case socketmode.EventTypeConnected:
+		log.Info().Msg("Connected to Slack with Socket Mode")
+	case socketmode.EventTypeHello, socketmode.EventTypeDisconnect:
+		log.Debug().Str("event_type", string(evt.Type)).Msg("Socket Mode connection event")
+	case socketmode.EventTypeSlashCommand:
+		form, err := commandForm(evt.Request.Payload)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to read slash command")
+			r.client.Ack(*evt.Request)
+			return
+		}
+		go r.serve(ctx, evt.Request, "/command", "application/x-www-form-urlencoded", []byte(form.Encode()))
+	case socketmode.EventTypeInteractive:
+		form := url.Values{"payload": {string(evt.Request.Payload)}}
+		go r.serve(ctx, evt.Request, "/interactive", "application/x-www-form-urlencoded", []byte(form.Encode()))
+	case socketmode.EventTypeEventsAPI:
+		go r.serve(ctx, evt.Request, "/events", "application/json", evt.Request.Payload)
+	default:
+		log.Error().Str("event_type", string(evt.Type)).Interface("data", evt.Data).Msg("Socket Mode error")
+	}
+}
+
+// serve - handle a request from Slack like the endpoint at path would, and acknowledge it with the response
+func (r *SocketModeRunner) serve(ctx context.Context, req *socketmode.Request, path, contentType string, body []byte) {
+	// Handlers add to the logger of the request, so every request needs its own
+	log := zerolog.Ctx(ctx).With().Str("envelope_id", req.EnvelopeID).Str("path", path).Logger()
+	ctx = log.WithContext(ctx)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create request")
+		r.client.Ack(*req)
+		return
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	w := &socketModeResponse{header: http.Header{}, status: http.StatusOK}
+	r.handler.ServeHTTP(w, httpReq)
+	if w.status >= http.StatusBadRequest {
+		log.Warn().Int("status", w.status).Msg("Socket Mode request failed")
+	}
+
+	if payload := w.payload(); payload != nil {
+		r.client.Ack(*req, payload)
+		return
+	}
+	r.client.Ack(*req)
+}
+
+// commandForm - the form Slack posts for a slash command, from the JSON of it sent over Socket Mode
+func commandForm(payload json.RawMessage) (url.Values, error) {
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	for k, v := range fields {
+		if s, ok := v.(string); ok {
+			form.Set(k, s)
+		} else {
+			form.Set(k, fmt.Sprint(v))
+		}
+	}
+	return form, nil
+}
+
+// socketModeResponse - records the response of a handler to send it back with the acknowledgement
+type socketModeResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *socketModeResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *socketModeResponse) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *socketModeResponse) WriteHeader(status int) {
+	w.status = status
+}
+
+// payload - the payload of the acknowledgement, JSON responses like modal errors and options as they are,
+// and text as a message to the user
+func (w *socketModeResponse) payload() interface{} {
+	body := bytes.TrimSpace(w.body.Bytes())
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	return map[string]string{"text": string(body)}
+}
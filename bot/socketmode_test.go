@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socketModeStandIn - a local stand-in for Slack that hands out a WebSocket URL and sends requests over it
+type socketModeStandIn struct {
+	*httptest.Server
+	envelopes chan string
+	acks      chan socketmode.Response
+}
+
+func newSocketModeStandIn(t *testing.T) *socketModeStandIn {
+	s := &socketModeStandIn{envelopes: make(chan string), acks: make(chan socketmode.Response)}
+	// The Socket Mode client connects with the origin of Slack
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer xapp-test", r.Header.Get("Authorization"))
+		fmt.Fprintf(w, `{"ok": true, "url": "ws://%s/ws"}`, r.Host)
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		if !assert.NoError(t, conn.WriteJSON(map[string]string{"type": "hello"})) {
+			return
+		}
+		for envelope := range s.envelopes {
+			if !assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(envelope))) {
+				return
+			}
+			var ack socketmode.Response
+			if !assert.NoError(t, conn.ReadJSON(&ack)) {
+				return
+			}
+			s.acks <- ack
+		}
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// send - send a request of a type with a payload and wait for its acknowledgement
+func (s *socketModeStandIn) send(t *testing.T, envelopeID, requestType, payload string) socketmode.Response {
+	s.envelopes <- fmt.Sprintf(`{"envelope_id": %q, "type": %q, "accepts_response_payload": true, "payload": %s}`,
+		envelopeID, requestType, payload)
+	select {
+	case ack := <-s.acks:
+		return ack
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s was not acknowledged", envelopeID)
+		return socketmode.Response{}
+	}
+}
+
+func TestSocketModeRunner(t *testing.T) {
+	s := newSocketModeStandIn(t)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	c := &dummyClient{
+		AuthTestResponse: &slack.AuthTestResponse{UserID: "UBOT"},
+		ChannelPages:     [][]slack.Channel{{newTestChannel("C1", "general"), newTestChannel("C2", "incidents")}},
+	}
+	h := &botHandler{slackClient: c, events: newEventDispatcher()}
+	changes := make(chan *slack.SubteamMembersChangedEvent, 1)
+	onEvent(h.events, eventSubteamMembersChanged, func(ctx context.Context, ev *slack.SubteamMembersChangedEvent) {
+		changes <- ev
+	})
+	client := socketmode.New(slack.New("xoxb-test", slack.OptionAppLevelToken("xapp-test"), slack.OptionAPIURL(s.URL+"/api/")))
+	r := newSocketModeRunner(client, h)
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx)
+	}()
+
+	// the response of an interaction comes back with its acknowledgement
+	ack := s.send(t, "1", socketmode.RequestTypeInteractive,
+		`{"type": "block_suggestion", "action_id": "broadcast_channel", "value": "inc"}`)
+	assert.Equal(t, "1", ack.EnvelopeID)
+	payload, err := json.Marshal(ack.Payload)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"options": [{"text": {"type": "plain_text", "text": "#incidents"}, "value": "C2"}]}`, string(payload))
+
+	// events are dispatched to their handlers
+	ack = s.send(t, "2", socketmode.RequestTypeEventsAPI,
+		`{"type": "event_callback", "event": {"type": "subteam_members_changed", "subteam_id": "S1"}}`)
+	assert.Equal(t, "2", ack.EnvelopeID)
+	assert.Nil(t, ack.Payload)
+	select {
+	case ev := <-changes:
+		assert.Equal(t, "S1", ev.SubteamID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not dispatched")
+	}
+
+	// text written in answer to a command becomes a message
+	c.err = ErrSlackUnavailable
+	ack = s.send(t, "3", socketmode.RequestTypeSlashCommands,
+		`{"command": "/devopsbot", "text": "help", "user_id": "U1", "response_url": "https://hooks.slack.com/commands/1"}`)
+	assert.Equal(t, "3", ack.EnvelopeID)
+	assert.Equal(t, map[string]interface{}{"text": ErrSlackUnavailable.Error()}, ack.Payload)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner did not stop")
+	}
+	close(s.envelopes)
+}
+
+func TestCommandForm(t *testing.T) {
+	form, err := commandForm(json.RawMessage(`{"command": "/devopsbot", "text": "status prod", "is_enterprise_install": false}`))
+	require.NoError(t, err)
+	assert.Equal(t, "/devopsbot", form.Get("command"))
+	assert.Equal(t, "status prod", form.Get("text"))
+	assert.Equal(t, "false", form.Get("is_enterprise_install"))
+
+	_, err = commandForm(json.RawMessage(`"text"`))
+	assert.Error(t, err)
+}
+
+func TestSocketModeResponsePayload(t *testing.T) {
+	w := &socketModeResponse{}
+	assert.Nil(t, w.payload())
+	w.body.WriteString(`{"response_action": "errors"}`)
+	assert.Equal(t, json.RawMessage(`{"response_action": "errors"}`), w.payload())
+
+	w = &socketModeResponse{}
+	w.body.WriteString(strings.Repeat(" ", 2) + "try again")
+	assert.Equal(t, map[string]string{"text": "try again"}, w.payload())
+}
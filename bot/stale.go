@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+// defaultStaleKey - the key of the thresholds by severity that applies to the severities missing from them
+const defaultStaleKey = "default"
+
+// Thresholds of staleness for the severities missing from the configured ones
+const (
+	defaultStaleNudgeAfter    = time.Hour
+	defaultStaleEscalateAfter = 2 * time.Hour
+)
+
+// StaleThresholds - how long an open incident may go without progress, by lowercase severity with "default" for
+// the severities missing from them. After Nudge the commander gets a direct message, and after Escalate the admin
+// user group is pinged in the incident channel and the incident is flagged as stale in the broadcast channel.
+type StaleThresholds struct {
+	Nudge    map[string]time.Duration
+	Escalate map[string]time.Duration
+}
+
+// ParseStaleThresholds - parse the thresholds of staleness by severity, with durations like "1h",
+// the nudge threshold of every severity must come before its escalation threshold
+func ParseStaleThresholds(nudge, escalate map[string]string) (StaleThresholds, error) {
+	var t StaleThresholds
+	var err error
+	if t.Nudge, err = parseSeverityDurations("stale nudge threshold", nudge); err != nil {
+		return StaleThresholds{}, err
+	}
+	if t.Escalate, err = parseSeverityDurations("stale escalation threshold", escalate); err != nil {
+		return StaleThresholds{}, err
+	}
+	severities := []string{defaultStaleKey}
+	for severity := range t.Nudge {
+		severities = append(severities, severity)
+	}
+	for severity := range t.Escalate {
+		severities = append(severities, severity)
+	}
+	for _, severity := range severities {
+		if t.nudgeAfter(severity) >= t.escalateAfter(severity) {
+			return StaleThresholds{}, fmt.Errorf("stale nudge threshold for severity %q must be shorter than its escalation threshold", severity)
+		}
+	}
+	return t, nil
+}
+
+func (t StaleThresholds) nudgeAfter(severity string) time.Duration {
+	return severityDuration(t.Nudge, defaultStaleKey, severity, defaultStaleNudgeAfter)
+}
+
+func (t StaleThresholds) escalateAfter(severity string) time.Duration {
+	return severityDuration(t.Escalate, defaultStaleKey, severity, defaultStaleEscalateAfter)
+}
+
+// isStale - whether the incident was escalated for being stale and there has been no progress since
+func isStale(inc *store.Incident) bool {
+	return !inc.StaleAt.IsZero() && !inc.StaleAt.Before(lastProgress(inc))
+}
+
+// detectStaleIncidents - nudge the commanders of open incidents without progress for longer than the nudge
+// threshold, and escalate the ones without progress for longer than the escalation threshold
+func (h *botHandler) detectStaleIncidents(ctx context.Context, now time.Time) {
+	log := zerolog.Ctx(ctx)
+	incidents, err := h.incidents.List(ctx, store.ListOptions{Status: store.StatusOpen})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not list open incidents to detect stale ones")
+		return
+	}
+	stale := 0
+	for _, inc := range incidents {
+		last := lastProgress(inc)
+		idle := now.Sub(last)
+		switch {
+		case isStale(inc):
+			stale++
+		case idle >= h.opts.StaleThresholds.escalateAfter(inc.Severity):
+			if err := h.escalateStale(ctx, inc, idle); err != nil {
+				log.Error().Err(err).Str("incident_channel", inc.ChannelID).Msg("Could not escalate stale incident")
+				continue
+			}
+			stale++
+			h.opts.Metrics.IncidentStale(inc.Severity)
+			if _, err := h.incidents.Update(ctx, inc.ChannelID, func(inc *store.Incident) error {
+				inc.StaleAt = now
+				return nil
+			}); err != nil {
+				log.Error().Err(err).Str("incident_channel", inc.ChannelID).Msg("Could not record stale incident")
+			}
+		case idle >= h.opts.StaleThresholds.nudgeAfter(inc.Severity) && inc.Commander != "" && inc.StaleNudgedAt.Before(last):
+			text := fmt.Sprintf("%s has had no progress update for %s. Post one with `/devopsbot update` in the incident channel, "+
+				"or hand the commander role over with `/devopsbot handover` if you can not keep it up to date",
+				incidentLabel(inc.Number, inc.ChannelID), formatAge(idle))
+			if err := h.sendMessage(ctx, inc.Commander, slack.MsgOptionText(text, false)); err != nil {
+				log.Error().Err(err).Str("incident_channel", inc.ChannelID).Msg("Could not nudge commander of stale incident")
+				continue
+			}
+			if _, err := h.incidents.Update(ctx, inc.ChannelID, func(inc *store.Incident) error {
+				inc.StaleNudgedAt = now
+				return nil
+			}); err != nil {
+				log.Error().Err(err).Str("incident_channel", inc.ChannelID).Msg("Could not record nudge of commander")
+			}
+		}
+	}
+	h.opts.Metrics.SetStaleIncidents(stale)
+}
+
+// escalateStale - ask the admin user group to check on a stale incident, and flag it as stale in the broadcast channel
+func (h *botHandler) escalateStale(ctx context.Context, inc *store.Incident, idle time.Duration) error {
+	log := zerolog.Ctx(ctx)
+	mentions := []string{":hourglass:"}
+	if h.opts.AdminGroupID != "" {
+		mentions = append(mentions, fmt.Sprintf("<!subteam^%s>", h.opts.AdminGroupID))
+	}
+	if inc.Commander != "" {
+		mentions = append(mentions, fmt.Sprintf("<@%s>", inc.Commander))
+	}
+	text := fmt.Sprintf("%s This incident has had no progress update for %s and is now flagged as stale. "+
+		"Check on it, and post an update with `/devopsbot update` or resolve it with `/devopsbot resolve`",
+		strings.Join(mentions, " "), formatAge(idle))
+	if err := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionText(text, false)); err != nil {
+		return err
+	}
+	if inc.BroadcastChannelID == "" {
+		return nil
+	}
+	text = fmt.Sprintf(":hourglass: %s is stale, there has been no progress update for %s",
+		incidentLabel(inc.Number, inc.ChannelID), formatAge(idle))
+	if err := h.sendMessage(ctx, inc.BroadcastChannelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Msg("Could not flag stale incident in broadcast channel")
+	}
+	return nil
+}
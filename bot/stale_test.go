@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/metrics"
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStaleThresholds(t *testing.T) {
+	thresholds, err := ParseStaleThresholds(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, defaultStaleNudgeAfter, thresholds.nudgeAfter("high"))
+	assert.Equal(t, defaultStaleEscalateAfter, thresholds.escalateAfter("high"))
+
+	thresholds, err = ParseStaleThresholds(map[string]string{"High": "15m"}, map[string]string{"high": "30m", "default": "4h"})
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, thresholds.nudgeAfter("high"))
+	assert.Equal(t, 30*time.Minute, thresholds.escalateAfter("HIGH"))
+	assert.Equal(t, defaultStaleNudgeAfter, thresholds.nudgeAfter("low"))
+	assert.Equal(t, 4*time.Hour, thresholds.escalateAfter("low"))
+
+	_, err = ParseStaleThresholds(map[string]string{"high": "later"}, nil)
+	assert.Error(t, err)
+	// the escalation must come after the nudge, also with the default thresholds
+	_, err = ParseStaleThresholds(map[string]string{"high": "1h"}, map[string]string{"high": "30m"})
+	assert.Error(t, err)
+	_, err = ParseStaleThresholds(map[string]string{"default": "3h"}, nil)
+	assert.Error(t, err)
+}
+
+func TestDetectStaleIncidents(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics.MetricsRegisterer = reg
+	defer func() { metrics.MetricsRegisterer = prometheus.DefaultRegisterer }()
+
+	ctx := context.TODO()
+	declared := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:          "C1",
+		Number:             3,
+		BroadcastChannelID: "B1",
+		Status:             store.StatusOpen,
+		Severity:           "high",
+		Commander:          "U1",
+		DeclaredAt:         declared,
+	}))
+	m := metrics.NewIncidentMetrics("test")
+	thresholds, err := ParseStaleThresholds(map[string]string{"high": "30m"}, map[string]string{"high": "1h"})
+	require.NoError(t, err)
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+		opts:        Opts{AdminGroupID: "S1", StaleThresholds: thresholds, Metrics: m},
+	}
+	assertStale := func(n int) {
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+# HELP test_incidents_stale The number of open incidents without a progress update for longer than their stale threshold
+# TYPE test_incidents_stale gauge
+test_incidents_stale %d
+`, n)), "test_incidents_stale"))
+	}
+
+	b.detectStaleIncidents(ctx, declared.Add(20*time.Minute))
+	assert.Empty(t, c.messages)
+
+	// the commander is nudged once
+	b.detectStaleIncidents(ctx, declared.Add(40*time.Minute))
+	b.detectStaleIncidents(ctx, declared.Add(45*time.Minute))
+	require.Len(t, c.messages, 1)
+	assert.Equal(t, "U1", c.messages[0].Get("channel"))
+	assert.Contains(t, c.messages[0].Get("text"), "INC-3 <#C1> has had no progress update for 40m")
+	assertStale(0)
+
+	// then the admins are pinged and the incident is flagged in the broadcast channel, once
+	b.detectStaleIncidents(ctx, declared.Add(70*time.Minute))
+	b.detectStaleIncidents(ctx, declared.Add(80*time.Minute))
+	require.Len(t, c.messages, 3)
+	assert.Equal(t, "C1", c.messages[1].Get("channel"))
+	assert.Contains(t, c.messages[1].Get("text"), "<!subteam^S1> <@U1>")
+	assert.Equal(t, "B1", c.messages[2].Get("channel"))
+	assert.Contains(t, c.messages[2].Get("text"), "INC-3 <#C1> is stale")
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.True(t, isStale(inc))
+	assertStale(1)
+
+	// a progress update makes the incident fresh again
+	_, err = incidents.Update(ctx, "C1", func(inc *store.Incident) error {
+		inc.Updates = append(inc.Updates, store.ProgressUpdate{At: declared.Add(90 * time.Minute)})
+		return nil
+	})
+	require.NoError(t, err)
+	b.detectStaleIncidents(ctx, declared.Add(100*time.Minute))
+	inc, err = incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.False(t, isStale(inc))
+	assertStale(0)
+	assert.Len(t, c.messages, 3)
+
+	// and the commander is nudged again when it goes stale again
+	b.detectStaleIncidents(ctx, declared.Add(125*time.Minute))
+	require.Len(t, c.messages, 4)
+	assert.Equal(t, "U1", c.messages[3].Get("channel"))
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/slack-go/slack"
+)
+
+// Slack allows at most 50 blocks in a message, leave room for the header and footer
+const maxListedIncidents = 45
+
+// cmdStatus - handler for listing open incidents, optionally only the ones
+// affecting the given environment or region
+func (h *botHandler) cmdStatus(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, filter string) error {
+	incidents, err := h.incidents.List(ctx, store.ListOptions{Status: store.StatusOpen})
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to list incidents: %s", err), err)
+	}
+	filter = strings.TrimSpace(filter)
+	matching := []*store.Incident{}
+	for _, inc := range incidents {
+		if incidentMatches(inc, filter) {
+			matching = append(matching, inc)
+		}
+	}
+
+	var headerText string
+	switch {
+	case len(matching) == 0 && filter != "":
+		headerText = h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "NoOpenIncidentsMatching",
+				Other: "There are no open incidents affecting {{.Filter}}"},
+			TemplateData: map[string]string{"Filter": filter},
+		})
+	case len(matching) == 0:
+		headerText = h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "NoOpenIncidents",
+				Other: "There are no open incidents"},
+		})
+	default:
+		headerText = h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "OpenIncidents",
+				One:   "{{.Count}} open incident",
+				Other: "{{.Count}} open incidents"},
+			PluralCount:  len(matching),
+			TemplateData: map[string]int{"Count": len(matching)},
+		})
+	}
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, headerText, false, false)),
+	}
+
+	severityLabel := h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "Severity",
+			Other: "Severity"},
+	})
+	impactLabel := h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "Impact",
+			Other: "Impact"},
+	})
+	commanderLabel := h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "Commander",
+			Other: "Commander"},
+	})
+	ageLabel := h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "Age",
+			Other: "Age"},
+	})
+	staleText := h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "Stale",
+			Other: "Stale"},
+	})
+	now := time.Now()
+	for i, inc := range matching {
+		if i == maxListedIncidents {
+			moreText := h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{
+					ID:    "MoreOpenIncidents",
+					Other: "…and {{.Count}} more"},
+				TemplateData: map[string]int{"Count": len(matching) - maxListedIncidents},
+			})
+			blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, moreText, false, false)))
+			break
+		}
+		text := fmt.Sprintf("*%s* %s\n*%s:* %s   *%s:* %s   *%s:* <@%s>   *%s:* %s",
+			incidentLabel(inc.Number, inc.ChannelID), inc.Summary,
+			severityLabel, inc.Severity,
+			impactLabel, inc.Impact,
+			commanderLabel, inc.Commander,
+			ageLabel, formatAge(now.Sub(inc.DeclaredAt)))
+		if isStale(inc) {
+			text += fmt.Sprintf("   :hourglass: *%s*", staleText)
+		}
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil))
+	}
+
+	if err := h.respond(ctx, cmd.ResponseURL, cmd.UserID, slack.ResponseTypeEphemeral,
+		slack.MsgOptionText(headerText, false),
+		slack.MsgOptionBlocks(blocks...),
+	); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// incidentMatches - whether the incident affects the environment or region in filter,
+// an empty filter matches every incident
+func incidentMatches(inc *store.Incident, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	for _, e := range inc.Environments {
+		if strings.EqualFold(e, filter) {
+			return true
+		}
+	}
+	for _, r := range inc.Regions {
+		if strings.EqualFold(r, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatAge - format a duration in a compact human readable way, for example "2d 3h" or "4h 5m"
+func formatAge(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
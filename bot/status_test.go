@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCmdStatus(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	c.User = &slack.User{
+		Locale: "en-US",
+	}
+	incidents := newTestStore(t)
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+	}
+	status := func(text string) {
+		w := httptest.NewRecorder()
+		v := url.Values{}
+		v.Set("user_id", "user")
+		v.Set("command", "/devopsbot")
+		v.Set("text", text)
+		b.handleCommand(w, newPostRequest(bytes.NewBufferString(v.Encode())))
+		assert.Equal(t, 200, w.Code)
+	}
+
+	status("status")
+	assert.Equal(t, "There are no open incidents", c.response["text"][0])
+
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:    "C1",
+		Status:       store.StatusOpen,
+		Severity:     "high",
+		Impact:       "low",
+		Commander:    "U1",
+		Environments: []string{"Production"},
+		Regions:      []string{"eu-west-1"},
+		DeclaredAt:   time.Now().Add(-90 * time.Minute),
+	}))
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:    "C2",
+		Status:       store.StatusResolved,
+		Environments: []string{"Production"},
+	}))
+
+	status("status")
+	assert.Equal(t, "1 open incident", c.response["text"][0])
+	blocks := messageBlocks(t, c.response)
+	require.Len(t, blocks, 2)
+	text := blocks[1].(*slack.SectionBlock).Text.Text
+	assert.Contains(t, text, "<#C1>")
+	assert.Contains(t, text, "<@U1>")
+	assert.Contains(t, text, "1h 30m")
+	assert.NotContains(t, text, "Stale")
+
+	_, err := incidents.Update(ctx, "C1", func(inc *store.Incident) error {
+		inc.StaleAt = time.Now()
+		return nil
+	})
+	require.NoError(t, err)
+	status("status")
+	blocks = messageBlocks(t, c.response)
+	require.Len(t, blocks, 2)
+	assert.Contains(t, blocks[1].(*slack.SectionBlock).Text.Text, ":hourglass: *Stale*")
+
+	status("status production")
+	assert.Equal(t, "1 open incident", c.response["text"][0])
+
+	status("status us-east-1")
+	assert.Equal(t, "There are no open incidents affecting us-east-1", c.response["text"][0])
+}
+
+func TestFormatAge(t *testing.T) {
+	assert.Equal(t, "0m", formatAge(30*time.Second))
+	assert.Equal(t, "59m", formatAge(59*time.Minute+59*time.Second))
+	assert.Equal(t, "2h 5m", formatAge(2*time.Hour+5*time.Minute))
+	assert.Equal(t, "3d 4h", formatAge(76*time.Hour+30*time.Minute))
+}
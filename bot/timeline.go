@@ -0,0 +1,291 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+var userRefRegex = regexp.MustCompile(`<@([A-Z0-9]+)>`)
+
+const (
+	timelineFormatMarkdown = "markdown"
+	timelineFormatJSON     = "json"
+
+	// maxTimelineMessageLength - Slack refuses too long messages, so a timeline is sent in parts of at most
+	// this many characters
+	maxTimelineMessageLength = 12000
+	// maxTimelineMessages - a response URL can only be used this many times
+	maxTimelineMessages = 5
+)
+
+// timelineExport - the JSON representation of an incident timeline
+type timelineExport struct {
+	Number      int           `json:"number,omitempty"`
+	ChannelID   string        `json:"channelID"`
+	ChannelName string        `json:"channelName"`
+	Events      []store.Event `json:"events"`
+}
+
+// cmdTimeline - handler for exporting the timeline of the incident given as argument,
+// or of the incident the command is run in. The arguments are an optional incident
+// like INC-42 and an optional format.
+func (h *botHandler) cmdTimeline(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, args string) error {
+	format := ""
+	channelID := cmd.ChannelID
+	for _, arg := range strings.Fields(args) {
+		if _, ok := parseIncidentRef(arg); !ok {
+			format = arg
+			continue
+		}
+		incidentChannelID, err := h.incidentArgChannel(ctx, arg)
+		if errors.Is(err, store.ErrNotFound) {
+			return h.errorResponse(ctx, w, cmd, h.unknownIncidentText(arg), nil)
+		}
+		if err != nil {
+			return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to get incident: %s", err), err)
+		}
+		channelID = incidentChannelID
+	}
+
+	inc, err := h.incidents.Get(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
+		return h.errorResponse(ctx, w, cmd, h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "RunInIncidentChannel",
+				Other: "Run this command in an incident channel"},
+		}), nil)
+	}
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to get incident: %s", err), err)
+	}
+	// The channels of security incidents are private, so their timelines are not shown outside of them
+	if inc.SecurityRelated && cmd.ChannelID != inc.ChannelID && !h.isAdmin(ctx, cmd.UserID) {
+		return h.errorResponse(ctx, w, cmd, "The timeline of a security incident can only be exported in its incident channel", nil)
+	}
+
+	b := &strings.Builder{}
+	switch format {
+	case "", timelineFormatMarkdown:
+		// Slack renders user references itself
+		err = writeTimelineMarkdown(b, inc, func(userID string) string { return fmt.Sprintf("<@%s>", userID) })
+	case timelineFormatJSON:
+		err = writeTimelineJSON(b, inc)
+	default:
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Unknown timeline format %q, use %q or %q", format, timelineFormatMarkdown, timelineFormatJSON), nil)
+	}
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to export timeline: %s", err), err)
+	}
+
+	// A markdown table can be split between its rows, JSON is only readable in one piece
+	var parts []string
+	if format == timelineFormatJSON {
+		if b.Len() <= maxTimelineMessageLength {
+			parts = []string{b.String()}
+		}
+	} else {
+		parts = splitLines(b.String(), maxTimelineMessageLength)
+	}
+	if len(parts) == 0 || len(parts) > maxTimelineMessages {
+		return h.uploadTimeline(ctx, w, cmd, inc, format, b.String())
+	}
+	for _, part := range parts {
+		if err := h.respond(ctx, cmd.ResponseURL, cmd.UserID, slack.ResponseTypeEphemeral,
+			slack.MsgOptionText("```\n"+part+"```", false),
+			slack.MsgOptionAttachments(),
+		); err != nil {
+			return err
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// uploadTimeline - upload a timeline that is too long for messages as a file to its incident channel, which
+// is only done when the command is run there so that nobody else sees the timeline
+func (h *botHandler) uploadTimeline(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, inc *store.Incident, format, content string) error {
+	if cmd.ChannelID != inc.ChannelID {
+		return h.errorResponse(ctx, w, cmd, "The timeline is too long for a message, run the command in the incident channel to get it as a file", nil)
+	}
+	filetype, ext := "markdown", "md"
+	if format == timelineFormatJSON {
+		filetype, ext = "json", "json"
+	}
+	if _, err := h.slackClient.UploadFileContext(ctx, slack.FileUploadParameters{
+		Content:  content,
+		Filetype: filetype,
+		Filename: fmt.Sprintf("timeline-%s.%s", inc.ChannelName, ext),
+		Title:    fmt.Sprintf("Timeline of #%s", inc.ChannelName),
+		Channels: []string{inc.ChannelID},
+	}); err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to upload timeline: %s", err), err)
+	}
+	if err := h.respond(ctx, cmd.ResponseURL, cmd.UserID, slack.ResponseTypeEphemeral,
+		slack.MsgOptionText("The timeline is too long for a message, so it is uploaded to this channel as a file", false),
+		slack.MsgOptionAttachments(),
+	); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// splitLines - split s into parts of at most max characters between lines, lines that are longer are split too
+func splitLines(s string, max int) []string {
+	parts := []string{}
+	part := ""
+	for _, line := range strings.SplitAfter(s, "\n") {
+		for len(line) > max {
+			if part != "" {
+				parts = append(parts, part)
+				part = ""
+			}
+			parts = append(parts, line[:max])
+			line = line[max:]
+		}
+		if len(part)+len(line) > max {
+			parts = append(parts, part)
+			part = ""
+		}
+		part += line
+	}
+	if part != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// writeTimelineMarkdown - write the timeline of an incident as a markdown table,
+// userName is used to turn Slack user IDs into something readable
+func writeTimelineMarkdown(w io.Writer, inc *store.Incident, userName func(userID string) string) error {
+	name := "#" + inc.ChannelName
+	if inc.ChannelName == "" {
+		name = "#" + inc.ChannelID
+	}
+	if inc.Number > 0 {
+		name = incidentRef(inc.Number) + " " + name
+	}
+	if _, err := fmt.Fprintf(w, "# Timeline of %s\n\n| Time (UTC) | Who | What |\n| --- | --- | --- |\n", name); err != nil {
+		return err
+	}
+	for _, e := range inc.Timeline {
+		text := userRefRegex.ReplaceAllStringFunc(e.Text, func(ref string) string {
+			return userName(userRefRegex.FindStringSubmatch(ref)[1])
+		})
+		// Keep every event on one row of the table
+		text = strings.NewReplacer("\n", " ", "|", "\\|").Replace(text)
+		if _, err := fmt.Fprintf(w, "| %s | %s | %s |\n", e.At.UTC().Format("2006-01-02 15:04:05"), userName(e.Actor), text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTimelineJSON - write the timeline of an incident as JSON
+func writeTimelineJSON(w io.Writer, inc *store.Incident) error {
+	events := inc.Timeline
+	if events == nil {
+		events = []store.Event{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(timelineExport{
+		Number:      inc.Number,
+		ChannelID:   inc.ChannelID,
+		ChannelName: inc.ChannelName,
+		Events:      events,
+	})
+}
+
+// userNames - resolve Slack user IDs to real names, falling back to the ID
+func (h *botHandler) userNames(ctx context.Context) func(userID string) string {
+	log := zerolog.Ctx(ctx)
+	names := map[string]string{}
+	return func(userID string) string {
+		if userID == "" {
+			return ""
+		}
+		if name, ok := names[userID]; ok {
+			return name
+		}
+		names[userID] = userID
+		user, err := h.slackClient.GetUserInfoContext(ctx, userID)
+		if err != nil {
+			log.Warn().Err(err).Str("user_id", userID).Msg("Could not resolve user name")
+			return userID
+		}
+		if user.RealName != "" {
+			names[userID] = user.RealName
+		} else if user.Name != "" {
+			names[userID] = user.Name
+		}
+		return names[userID]
+	}
+}
+
+// NewTimelineHandler - create a handler exporting incident timelines at /<incident channel ID>,
+// as markdown by default or as JSON with ?format=json. Timelines of security
+// related incidents are never exported.
+func NewTimelineHandler(slackClient SlackClient, incidents store.IncidentStore) http.Handler {
+	h := &botHandler{
+		slackClient: slackClient,
+		incidents:   incidents,
+	}
+	return http.HandlerFunc(h.handleTimeline)
+}
+
+func (h *botHandler) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := zerolog.Ctx(ctx)
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	channelID := strings.Trim(r.URL.Path, "/")
+	inc, err := h.incidents.Get(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && inc.SecurityRelated) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Could not get incident")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", timelineFormatMarkdown:
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Last-Modified", lastEventTime(inc).UTC().Format(http.TimeFormat))
+		err = writeTimelineMarkdown(w, inc, h.userNames(ctx))
+	case timelineFormatJSON:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = writeTimelineJSON(w, inc)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Could not write timeline")
+	}
+}
+
+// lastEventTime - when the last thing happened in an incident
+func lastEventTime(inc *store.Incident) time.Time {
+	if len(inc.Timeline) == 0 {
+		return inc.DeclaredAt
+	}
+	return inc.Timeline[len(inc.Timeline)-1].At
+}
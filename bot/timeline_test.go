@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTimelineIncident(t *testing.T, incidents store.IncidentStore, channelID string, number int, securityRelated bool) {
+	at := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	inc := &store.Incident{
+		ChannelID:       channelID,
+		Number:          number,
+		ChannelName:     "inc_db_down",
+		Status:          store.StatusOpen,
+		SecurityRelated: securityRelated,
+		DeclaredAt:      at,
+	}
+	inc.AddEvent(at, "U1", store.EventDeclared, "Declared the incident: DB | down")
+	inc.AddEvent(at.Add(time.Minute), "U1", store.EventRoleChanged, "Handed over the commander role from <@U1> to <@U2>")
+	require.NoError(t, incidents.Create(context.TODO(), inc))
+}
+
+func TestCmdTimeline(t *testing.T) {
+	c := &dummyClient{}
+	c.User = &slack.User{
+		Locale: "en-US",
+	}
+	incidents := newTestStore(t)
+	newTimelineIncident(t, incidents, "C1", 7, false)
+	newTimelineIncident(t, incidents, "C3", 9, true)
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+		admins:      &ugMembers{},
+	}
+	timeline := func(channelID, text string) {
+		w := httptest.NewRecorder()
+		v := url.Values{}
+		v.Set("user_id", "user")
+		v.Set("channel_id", channelID)
+		v.Set("command", "/devopsbot")
+		v.Set("text", text)
+		b.handleCommand(w, newPostRequest(bytes.NewBufferString(v.Encode())))
+		assert.Equal(t, 200, w.Code)
+	}
+
+	timeline("C2", "timeline")
+	assert.Equal(t, "Run this command in an incident channel", c.response["text"][0])
+
+	timeline("C1", "timeline")
+	assert.Contains(t, c.response["text"][0], "| 2022-07-01 12:01:00 | <@U1> | Handed over the commander role from <@U1> to <@U2> |")
+
+	timeline("C1", "timeline json")
+	assert.Contains(t, c.response["text"][0], `"kind": "declared"`)
+
+	// an incident can be given from any channel
+	timeline("C2", "timeline INC-7 json")
+	assert.Contains(t, c.response["text"][0], `"number": 7`)
+
+	timeline("C1", "timeline INC-8")
+	assert.Equal(t, "There is no incident INC-8", c.response["text"][0])
+
+	// the timeline of a security incident is only shown in its channel, or to admins
+	timeline("C2", "timeline INC-9")
+	assert.Equal(t, "The timeline of a security incident can only be exported in its incident channel", c.response["text"][0])
+	timeline("C3", "timeline INC-9")
+	assert.Contains(t, c.response["text"][0], "Handed over the commander role")
+	c.members = []string{"user"}
+	b.admins = &ugMembers{}
+	timeline("C2", "timeline INC-9")
+	assert.Contains(t, c.response["text"][0], "Handed over the commander role")
+
+	// a long timeline is sent in several messages
+	_, err := incidents.Update(context.TODO(), "C1", func(inc *store.Incident) error {
+		for i := 0; i < 100; i++ {
+			inc.AddEvent(inc.DeclaredAt.Add(time.Hour), "U1", store.EventUpdated, strings.Repeat("x", 100))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	c.messages = nil
+	timeline("C1", "timeline")
+	assert.Equal(t, 2, len(c.messages))
+	for _, m := range c.messages {
+		assert.LessOrEqual(t, len(m["text"][0]), maxTimelineMessageLength+8)
+	}
+	assert.Empty(t, c.uploads)
+
+	// and one that is too long for messages is uploaded to the incident channel as a file
+	_, err = incidents.Update(context.TODO(), "C1", func(inc *store.Incident) error {
+		for i := 0; i < 1000; i++ {
+			inc.AddEvent(inc.DeclaredAt.Add(time.Hour), "U1", store.EventUpdated, strings.Repeat("x", 100))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	timeline("C2", "timeline INC-7")
+	assert.Equal(t, "The timeline is too long for a message, run the command in the incident channel to get it as a file", c.response["text"][0])
+	assert.Empty(t, c.uploads)
+	timeline("C1", "timeline json")
+	require.Len(t, c.uploads, 1)
+	assert.Equal(t, "timeline-inc_db_down.json", c.uploads[0].Filename)
+	assert.Equal(t, []string{"C1"}, c.uploads[0].Channels)
+	assert.Equal(t, "The timeline is too long for a message, so it is uploaded to this channel as a file", c.response["text"][0])
+}
+
+func TestSplitLines(t *testing.T) {
+	assert.Equal(t, []string{}, splitLines("", 4))
+	assert.Equal(t, []string{"ab\n", "cd\ne"}, splitLines("ab\ncd\ne", 4))
+	assert.Equal(t, []string{"ab\n", "cdef", "gh\ni"}, splitLines("ab\ncdefgh\ni", 4))
+}
+
+func TestTimelineHandler(t *testing.T) {
+	c := &dummyClient{}
+	c.User = &slack.User{
+		RealName: "Jane Doe",
+	}
+	incidents := newTestStore(t)
+	newTimelineIncident(t, incidents, "C1", 0, false)
+	newTimelineIncident(t, incidents, "C2", 0, true)
+	h := NewTimelineHandler(c, incidents)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/C1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "# Timeline of #inc_db_down\n\n"+
+		"| Time (UTC) | Who | What |\n"+
+		"| --- | --- | --- |\n"+
+		"| 2022-07-01 12:00:00 | Jane Doe | Declared the incident: DB \\| down |\n"+
+		"| 2022-07-01 12:01:00 | Jane Doe | Handed over the commander role from Jane Doe to Jane Doe |\n",
+		w.Body.String())
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/C1?format=json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	export := timelineExport{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &export))
+	assert.Equal(t, "C1", export.ChannelID)
+	require.Len(t, export.Events, 2)
+	assert.Equal(t, store.EventRoleChanged, export.Events[1].Kind)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/C1?format=pdf", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// security related incidents are not exported
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/C2", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/C3", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/C1", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
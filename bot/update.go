@@ -0,0 +1,237 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/internal/wrappedcontext"
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"github.com/slack-go/slack"
+)
+
+// The stages an incident goes through while it is being worked on
+var incidentProgressStatuses = []string{"Investigating", "Identified", "Monitoring"}
+
+// The choices for when the next update is due, in a format time.ParseDuration understands
+var nextUpdateETAs = []string{"15m", "30m", "1h", "2h", "4h"}
+
+const defaultNextUpdateETA = "30m"
+
+// cmdUpdateIncident - handler for posting a progress update about an incident
+func (h *botHandler) cmdUpdateIncident(ctx context.Context, w http.ResponseWriter, cmd slack.SlashCommand, incident string) error {
+	initialChannelID, err := h.incidentArgChannel(ctx, incident)
+	if errors.Is(err, store.ErrNotFound) {
+		return h.errorResponse(ctx, w, cmd, h.unknownIncidentText(incident), nil)
+	}
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Failed to get incident: %s", err), err)
+	}
+
+	_, err = h.slackClient.OpenViewContext(ctx, cmd.TriggerID, h.updateIncidentModal(initialChannelID))
+	if err != nil {
+		return h.errorResponse(ctx, w, cmd, fmt.Sprintf("Error opening view: %s", err), err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// updateIncidentModal - the modal for posting a progress update, with the given incident channel chosen if any
+func (h *botHandler) updateIncidentModal(initialChannelID string) slack.ModalViewRequest {
+	titleText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "UpdateAnIncident",
+				Other: "Update an incident"},
+		}), false, false)
+	closeText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "Cancel",
+				Other: "Cancel"},
+		}), false, false)
+	submitText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "PostUpdate",
+				Other: "Post update"},
+		}), false, false)
+
+	contextText := slack.NewTextBlockObject(slack.MarkdownType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "UpdateIncidentDescription",
+				Other: "This will post a progress update in the incident channel and in the broadcast channel"},
+		}), false, false)
+	contextBlock := slack.NewContextBlock("context", contextText)
+
+	statusTxt := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "CurrentStatus",
+				Other: "Current status"},
+		}), false, false)
+	statusOptions := createOptionBlockObjects(incidentProgressStatuses, "")
+	statusOptionsBlock := slack.NewRadioButtonsBlockElement("incident_status", statusOptions...)
+	statusBlock := slack.NewInputBlock("incident_status", statusTxt, nil, statusOptionsBlock)
+
+	changesText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "WhatChanged",
+				Other: "What changed"},
+		}), false, false)
+	changesElement := slack.NewPlainTextInputBlockElement(changesText, "incident_changes")
+	changesElement.MaxLength = 500
+	changesElement.Multiline = true
+	changesBlock := slack.NewInputBlock("incident_changes", changesText, nil, changesElement)
+
+	etaText := slack.NewTextBlockObject(slack.PlainTextType,
+		h.opts.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "NextUpdate",
+				Other: "Next update in"},
+		}), false, false)
+	etaOptions := createOptionBlockObjects(nextUpdateETAs, "")
+	etaOption := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, nil, "incident_next_update", etaOptions...)
+	for _, o := range etaOptions {
+		if o.Value == defaultNextUpdateETA {
+			etaOption.InitialOption = o
+		}
+	}
+	etaBlock := slack.NewInputBlock("incident_next_update", etaText, nil, etaOption)
+
+	blocks := slack.Blocks{
+		BlockSet: []slack.Block{
+			contextBlock,
+			h.incidentChannelBlock(initialChannelID),
+			statusBlock,
+			changesBlock,
+			etaBlock,
+		},
+	}
+
+	var modalVReq slack.ModalViewRequest
+	modalVReq.Type = slack.ViewType("modal")
+	modalVReq.Title = titleText
+	modalVReq.Close = closeText
+	modalVReq.Submit = submitText
+	modalVReq.Blocks = blocks
+	modalVReq.ClearOnClose = true
+	modalVReq.CallbackID = "update_incident"
+	return modalVReq
+}
+
+type updateParams struct {
+	// Incident channel ID
+	incidentChannel string
+	// Current stage of the incident
+	incidentStatus string
+	// What changed since the last update
+	incidentChanges string
+	// When the next update is due
+	nextUpdateIn time.Duration
+	// Who posted the update
+	incidentUpdater string
+}
+
+// postIncidentUpdate - handler for progress updates
+func (h *botHandler) postIncidentUpdate(ctx context.Context, payload *slack.InteractionCallback, w http.ResponseWriter) error {
+	incidentChannelID := submittedIncidentChannel(payload)
+	if err := h.validateOpenIncident(ctx, incidentField(payload, "incident_status"), incidentChannelID); err != nil {
+		var verr *validationError
+		if errors.As(err, &verr) {
+			return postErrorResponse(ctx, verr.errors, w)
+		}
+		return err
+	}
+	nextUpdateIn, err := time.ParseDuration(payload.View.State.Values["incident_next_update"]["incident_next_update"].SelectedOption.Value)
+	if err != nil {
+		return postErrorResponse(ctx, map[string]string{
+			"incident_next_update": fmt.Sprintf("invalid next update: %s", err),
+		}, w)
+	}
+	updateParams := &updateParams{
+		incidentChannel: incidentChannelID,
+		incidentStatus:  payload.View.State.Values["incident_status"]["incident_status"].SelectedOption.Value,
+		incidentChanges: payload.View.State.Values["incident_changes"]["incident_changes"].Value,
+		nextUpdateIn:    nextUpdateIn,
+		incidentUpdater: payload.User.ID,
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	// Do the rest via goroutine, which outlives the request and can wait for Slack to recover
+	ctx = waitForSlack(wrappedcontext.WrapContextValues(context.Background(), ctx))
+	go h.doUpdateTasks(ctx, updateParams)
+
+	return nil
+}
+
+// doUpdateTasks - record a progress update and announce it
+func (h *botHandler) doUpdateTasks(ctx context.Context, params *updateParams) {
+	log := zerolog.Ctx(ctx)
+	now := time.Now()
+	update := store.ProgressUpdate{
+		At:           now,
+		Author:       params.incidentUpdater,
+		Status:       params.incidentStatus,
+		Changes:      params.incidentChanges,
+		NextUpdateAt: now.Add(params.nextUpdateIn),
+	}
+	var lastUpdateAt time.Time
+	inc, err := h.incidents.Update(ctx, params.incidentChannel, func(inc *store.Incident) error {
+		lastUpdateAt = inc.DeclaredAt
+		if len(inc.Updates) > 0 {
+			lastUpdateAt = inc.Updates[len(inc.Updates)-1].At
+		}
+		inc.Updates = append(inc.Updates, update)
+		inc.AddEvent(update.At, update.Author, store.EventUpdated, fmt.Sprintf("Posted an update (%s): %s", update.Status, update.Changes))
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Could not record incident update")
+		return
+	}
+	if !lastUpdateAt.IsZero() {
+		h.opts.Metrics.IncidentUpdated(update.At.Sub(lastUpdateAt))
+	}
+
+	text := fmt.Sprintf(":memo: Update on %s by <@%s>\n"+
+		"*Status:* %s\n"+
+		"*What changed:* %s\n"+
+		"*Next update:* <!date^%d^{time}|%s>",
+		incidentLabel(inc.Number, inc.ChannelID), update.Author, update.Status, update.Changes,
+		update.NextUpdateAt.Unix(), update.NextUpdateAt.UTC().Format(time.Kitchen+" MST"))
+	if err := h.sendMessage(ctx, inc.BroadcastChannelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Msg("Could not send update to broadcast channel")
+	}
+	if err := h.sendMessage(ctx, inc.ChannelID, slack.MsgOptionText(text, false)); err != nil {
+		log.Error().Err(err).Msg("Could not send update to incident channel")
+	}
+	h.notifySubscribers(ctx, inc, text)
+}
+
+// validateOpenIncident - validate that the chosen channel belongs to an open incident
+func (h *botHandler) validateOpenIncident(ctx context.Context, field string, channelID string) error {
+	inc, err := h.incidents.Get(ctx, channelID)
+	if errors.Is(err, store.ErrNotFound) {
+		return &validationError{
+			errors: map[string]string{field: valUnknownIncident},
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if inc.Status != store.StatusOpen {
+		return &validationError{
+			errors: map[string]string{field: valIncidentNotOpen},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/karl-johan-grahn/devopsbot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostIncidentUpdate(t *testing.T) {
+	ctx := context.TODO()
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{ChannelID: "C1", Status: store.StatusResolved}))
+	b := &botHandler{
+		slackClient: &dummyClient{},
+		incidents:   incidents,
+	}
+	submit := func(channelID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		b.handleInteractive(w, newInteractiveRequest(t, slack.InteractionCallback{
+			Type: slack.InteractionTypeViewSubmission,
+			View: slack.View{
+				CallbackID: "update_incident",
+				State: &slack.ViewState{
+					Values: map[string]map[string]slack.BlockAction{
+						"incident_channel":     {"incident_channel": {SelectedConversation: channelID}},
+						"incident_next_update": {"incident_next_update": {SelectedOption: slack.OptionBlockObject{Value: "30m"}}},
+					},
+				},
+			},
+		}))
+		return w
+	}
+
+	w := submit("doesnotexist")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), valUnknownIncident)
+
+	w = submit("C1")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), valIncidentNotOpen)
+}
+
+func TestDoUpdateTasks(t *testing.T) {
+	ctx := context.TODO()
+	c := &dummyClient{}
+	incidents := newTestStore(t)
+	require.NoError(t, incidents.Create(ctx, &store.Incident{
+		ChannelID:          "C1",
+		BroadcastChannelID: "B1",
+		Status:             store.StatusOpen,
+	}))
+	b := &botHandler{
+		slackClient: c,
+		incidents:   incidents,
+	}
+
+	b.doUpdateTasks(ctx, &updateParams{
+		incidentChannel: "C1",
+		incidentStatus:  "Identified",
+		incidentChanges: "Found the faulty deployment",
+		nextUpdateIn:    time.Hour,
+		incidentUpdater: "U1",
+	})
+
+	inc, err := incidents.Get(ctx, "C1")
+	require.NoError(t, err)
+	require.Len(t, inc.Updates, 1)
+	assert.Equal(t, "U1", inc.Updates[0].Author)
+	assert.Equal(t, "Identified", inc.Updates[0].Status)
+	assert.Equal(t, time.Hour, inc.Updates[0].NextUpdateAt.Sub(inc.Updates[0].At))
+
+	require.Len(t, c.messages, 2)
+	assert.Equal(t, "B1", c.messages[0].Get("channel"))
+	assert.Equal(t, "C1", c.messages[1].Get("channel"))
+	assert.Contains(t, c.messages[0].Get("text"), "Found the faulty deployment")
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/gorilla/handlers"
@@ -13,8 +15,12 @@ import (
 	"github.com/karl-johan-grahn/devopsbot/bot"
 	"github.com/karl-johan-grahn/devopsbot/config"
 	"github.com/karl-johan-grahn/devopsbot/internal/middleware"
+	"github.com/karl-johan-grahn/devopsbot/metrics"
+	"github.com/karl-johan-grahn/devopsbot/oncall"
+	"github.com/karl-johan-grahn/devopsbot/store"
 	"github.com/karl-johan-grahn/devopsbot/version"
 	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
@@ -28,6 +34,8 @@ const (
 	tlsAddr = "tls.addr"
 	tlsCert = "tls.cert"
 	tlsKey  = "tls.key"
+	// internalAddr - where the endpoints that are not signed by Slack are served
+	internalAddr = "internal.addr"
 	// This is not a hardcoded credential but simply a convenience reference to the secret name
 	//nolint:gosec
 	slackBotAccessToken  = "slack.botAccessToken"
@@ -35,8 +43,16 @@ const (
 	slackSigningSecret   = "slack.signingSecret"
 	slackAdminGroup      = "slack.adminGroupID"
 	broadcastChannelID   = "slack.broadcastChannelID"
+	storePath            = "store.path"
+	slackCacheTTL        = "slack.cacheTTL"
+	adminRefreshInterval = "slack.adminRefreshInterval"
+	slackSocketMode      = "slack.socketMode"
+	slackAppToken        = "slack.appToken"
 )
 
+// slackTimeout - how long a call to the Slack API may take, so that a hanging call fails and can be retried
+const slackTimeout = 30 * time.Second
+
 func initFlags(cmd *cobra.Command) {
 	cmd.Flags().SortFlags = false
 
@@ -45,15 +61,22 @@ func initFlags(cmd *cobra.Command) {
 	cmd.Flags().String(tlsAddr, ":3443", "address:port to listen on for TLS")
 	cmd.Flags().String(tlsCert, "devopsbot.pem", "Path to TLS certificate")
 	cmd.Flags().String(tlsKey, "devopsbot-key.pem", "Path to TLS private key")
+	cmd.Flags().String(internalAddr, "127.0.0.1:3334", "address:port to serve the timeline export on, which is not signed by Slack, empty to not serve it")
 
 	cmd.Flags().BoolP("verbose", "v", false, "Output extra logs")
 	cmd.Flags().BoolP("trace", "t", false, "Output trace logs")
 
 	cmd.Flags().String(slackBotAccessToken, "", "Slack bot access token")
-	cmd.Flags().String(slackUserAccessToken, "", "Slack user access token")
+	cmd.Flags().String(slackUserAccessToken, "", "Slack user access token with the reminders:write scope, only needed to delete the channel reminders added by versions before 0.37.0")
 	cmd.Flags().String(slackSigningSecret, "", "Slack bot signing secret")
+	cmd.Flags().Bool(slackSocketMode, false, "Receive Slack requests over Socket Mode instead of on the /bot/ endpoints")
+	cmd.Flags().String(slackAppToken, "", "Slack app-level token with the connections:write scope, needed for Socket Mode")
 	cmd.Flags().String(slackAdminGroup, "", "Slack ID for the admin user group")
 	cmd.Flags().String(broadcastChannelID, "", "Slack ID for the channel to use as the broadcast channel")
+	cmd.Flags().Duration(slackCacheTTL, 5*time.Minute, "How long Slack user info, channel info and the channels of the bot are cached")
+	cmd.Flags().Duration(adminRefreshInterval, 5*time.Minute, "How often the members of the admin user group are fetched")
+
+	cmd.Flags().String(storePath, "incidents.json", "Path to the file the incident records are kept in")
 
 	cmd.Flags().String("config", "config.yaml", "Config file to read (optional)")
 
@@ -106,15 +129,33 @@ func initConfig(cmd *cobra.Command) func() {
 		_ = viper.BindEnv("incident.regions", "incident.regions")
 		_ = viper.BindEnv("incident.severityLevels", "incident.severityLevels")
 		_ = viper.BindEnv("incident.impactLevels", "incident.impactLevels")
+		_ = viper.BindEnv("incident.postmortemTemplate", "incident.postmortemTemplate")
+		_ = viper.BindEnv("incident.reminderIntervals", "incident.reminderIntervals")
+		_ = viper.BindEnv("incident.stale.nudgeAfter", "incident.stale.nudgeAfter")
+		_ = viper.BindEnv("incident.stale.escalateAfter", "incident.stale.escalateAfter")
+		_ = viper.BindEnv("incident.acknowledgement.windows", "incident.acknowledgement.windows")
+		_ = viper.BindEnv("incident.acknowledgement.backups", "incident.acknowledgement.backups")
+		_ = viper.BindEnv("incident.channelName.template", "incident.channelName.template")
+		_ = viper.BindEnv("incident.channelName.dateLayout", "incident.channelName.dateLayout")
+		_ = viper.BindEnv("incident.channelName.timezone", "incident.channelName.timezone")
 		_ = viper.BindEnv("addr", "addr")
 		_ = viper.BindEnv(tlsAddr, tlsAddr)
 		_ = viper.BindEnv(tlsCert, tlsCert)
 		_ = viper.BindEnv(tlsKey, tlsKey)
+		_ = viper.BindEnv(internalAddr, internalAddr)
 		_ = viper.BindEnv(slackBotAccessToken, slackBotAccessToken)
 		_ = viper.BindEnv(slackUserAccessToken, slackUserAccessToken)
 		_ = viper.BindEnv(slackSigningSecret, slackSigningSecret)
 		_ = viper.BindEnv(slackAdminGroup, slackAdminGroup)
 		_ = viper.BindEnv(broadcastChannelID, broadcastChannelID)
+		_ = viper.BindEnv(storePath, storePath)
+		_ = viper.BindEnv(slackCacheTTL, slackCacheTTL)
+		_ = viper.BindEnv(adminRefreshInterval, adminRefreshInterval)
+		_ = viper.BindEnv(slackSocketMode, slackSocketMode)
+		_ = viper.BindEnv(slackAppToken, slackAppToken)
+		_ = viper.BindEnv("permissions", "permissions")
+		_ = viper.BindEnv("oncall.schedules", "oncall.schedules")
+		_ = viper.BindEnv("oncall.responderTeam", "oncall.responderTeam")
 	}
 }
 
@@ -155,12 +196,63 @@ func newCmd() *cobra.Command {
 				return err
 			}
 
-			slackClient := slack.New(cfg.SlackBotAccessToken,
+			// Every attempt of a retried call is instrumented, so that rate limiting shows in the metrics,
+			// and answers from the cache are neither retried nor instrumented
+			slackClient := bot.NewCachingSlackClient(bot.NewResilientSlackClient(bot.NewInstrumentedSlackClient(slack.New(cfg.SlackBotAccessToken,
 				slack.OptionDebug(viper.GetBool("verbose")),
-				slack.OptionHTTPClient(&http.Client{Transport: &spyTransport{rt: http.DefaultTransport}}),
-			)
+				slack.OptionHTTPClient(&http.Client{Timeout: slackTimeout, Transport: &spyTransport{rt: http.DefaultTransport}}),
+			), metrics.NewSlackAPIMetrics(cfg.NS))), cfg.SlackCacheTTL, metrics.NewSlackCacheMetrics(cfg.NS))
+			incidents, err := store.NewFileStore(cfg.StorePath)
+			if err != nil {
+				return err
+			}
+			incidentMetrics := metrics.NewIncidentMetrics(cfg.NS)
+			openIncidents, err := incidents.List(ctx, store.ListOptions{Status: store.StatusOpen})
+			if err != nil {
+				return err
+			}
+			incidentMetrics.SetOpenIncidents(len(openIncidents))
+			postmortemTemplate := ""
+			if cfg.PostmortemTemplatePath != "" {
+				b, err := os.ReadFile(cfg.PostmortemTemplatePath)
+				if err != nil {
+					return fmt.Errorf("failed to read postmortem template: %w", err)
+				}
+				postmortemTemplate = string(b)
+			}
+			postmortemTmpl, err := bot.ParsePostmortemTemplate(postmortemTemplate)
+			if err != nil {
+				return err
+			}
+			channelNamer, err := bot.NewChannelNamer(cfg.ChannelNameTemplate, cfg.ChannelNameDateLayout, cfg.ChannelNameTimezone)
+			if err != nil {
+				return err
+			}
+			permissions, err := bot.NewPermissionPolicy(cfg.Permissions)
+			if err != nil {
+				return err
+			}
+			reminderIntervals, err := bot.ParseReminderIntervals(cfg.ReminderIntervals)
+			if err != nil {
+				return err
+			}
+			staleThresholds, err := bot.ParseStaleThresholds(cfg.StaleNudgeAfter, cfg.StaleEscalateAfter)
+			if err != nil {
+				return err
+			}
+			acknowledgementWindows, err := bot.ParseAcknowledgementWindows(cfg.AcknowledgementWindows)
+			if err != nil {
+				return err
+			}
+			onCall, err := oncall.LoadSchedules(cfg.OnCallSchedules)
+			if err != nil {
+				return err
+			}
+			onCallResponderTeam := strings.ToLower(cfg.OnCallResponderTeam)
+			if _, ok := onCall[onCallResponderTeam]; onCallResponderTeam != "" && !ok {
+				return fmt.Errorf("oncall.responderTeam %q has no schedule in oncall.schedules", cfg.OnCallResponderTeam)
+			}
 			opts := bot.Opts{
-				UserAccessToken:        cfg.SlackUserAccessToken,
 				SigningSecret:          cfg.SlackSigningSecret,
 				AdminGroupID:           cfg.SlackAdminGroupID,
 				BroadcastChannelID:     cfg.BroadcastChannelID,
@@ -169,12 +261,48 @@ func newCmd() *cobra.Command {
 				IncidentRegions:        cfg.IncidentRegions,
 				IncidentSeverityLevels: cfg.IncidentSeverityLevels,
 				IncidentImpactLevels:   cfg.IncidentImpactLevels,
+				IncidentStore:          incidents,
+				ChannelNamer:           channelNamer,
+				PostmortemTemplate:     postmortemTmpl,
+				Metrics:                incidentMetrics,
+				Permissions:            permissions,
+				AdminRefreshInterval:   cfg.SlackAdminRefreshInterval,
+				AdminMetrics:           metrics.NewAdminGroupMetrics(cfg.NS),
+				ReminderIntervals:      reminderIntervals,
+				StaleThresholds:        staleThresholds,
+				AcknowledgementWindows: acknowledgementWindows,
+				AcknowledgementBackups: cfg.AcknowledgementBackups,
+				OnCall:                 onCall,
+				OnCallResponderTeam:    onCallResponderTeam,
 			}
 			log.Debug().Msgf("opts: %#v", opts)
+			// Set after logging the options, so that the user access token is not logged
+			if cfg.SlackUserAccessToken != "" {
+				opts.ReminderClient = slack.New(cfg.SlackUserAccessToken,
+					slack.OptionHTTPClient(&http.Client{Timeout: slackTimeout}))
+			}
 
 			mux := http.NewServeMux()
 			mux.Handle("/", devopsbot.HealthHandler(cfg.NS))
-			mux.Handle("/bot/", http.StripPrefix("/bot", bot.NewBot(slackClient, opts)))
+			// Socket Mode fails when its connection can not be made again
+			socketModeErrs := make(chan error, 1)
+			if cfg.SlackSocketMode {
+				if cfg.SlackAppToken == "" {
+					return fmt.Errorf("%s is needed for Socket Mode", slackAppToken)
+				}
+				socketClient := socketmode.New(slack.New(cfg.SlackBotAccessToken,
+					slack.OptionAppLevelToken(cfg.SlackAppToken),
+					slack.OptionDebug(viper.GetBool("verbose")),
+					slack.OptionHTTPClient(&http.Client{Timeout: slackTimeout, Transport: &spyTransport{rt: http.DefaultTransport}}),
+				), socketmode.OptionDebug(viper.GetBool("verbose")))
+				runner := bot.NewSocketModeRunner(ctx, socketClient, slackClient, opts)
+				go func() {
+					log.Info().Msg("receiving Slack requests over Socket Mode")
+					socketModeErrs <- runner.Run(ctx)
+				}()
+			} else {
+				mux.Handle("/bot/", http.StripPrefix("/bot", bot.NewBot(ctx, slackClient, opts)))
+			}
 
 			h := http.Handler(mux)
 			h = middleware.Logger(ctx, handlers.CompressHandler(h))
@@ -196,22 +324,47 @@ func newCmd() *cobra.Command {
 				}
 			}()
 
+			// Not signed by Slack, so served on its own listener that is kept off the public endpoints
+			var internalSrv *http.Server
+			if cfg.InternalAddr != "" {
+				internalMux := http.NewServeMux()
+				internalMux.Handle("/timeline/", http.StripPrefix("/timeline", bot.NewTimelineHandler(slackClient, incidents)))
+				internalSrv = &http.Server{Addr: cfg.InternalAddr, Handler: middleware.Logger(ctx, handlers.CompressHandler(internalMux))}
+				go func() {
+					log.Info().Str("addr", cfg.InternalAddr).Msg("listening on HTTP for internal requests")
+					if err := internalSrv.ListenAndServe(); err != nil {
+						log.Error().Err(err).Send()
+					}
+				}()
+			}
+
 			ch := make(chan os.Signal, 1)
 			// Handle SIGINT (Ctrl+C)
 			signal.Notify(ch, os.Interrupt)
-			<-ch
+			select {
+			case <-ch:
+			case err = <-socketModeErrs:
+				log.Error().Err(err).Msg("Socket Mode stopped")
+			}
 
-			// Parent already defers cancel above, so disable false positive lint
-			//nolint:govet
-			ctx, _ = context.WithTimeout(ctx, 15*time.Second)
+			ctx, cancelShutdown := context.WithTimeout(ctx, 15*time.Second)
+			defer cancelShutdown()
 
 			log.Info().Msg("shutting down")
 
-			err = httpSrv.Shutdown(ctx)
-			if err != nil {
-				return err
+			if shutdownErr := httpSrv.Shutdown(ctx); shutdownErr != nil {
+				return shutdownErr
+			}
+			if shutdownErr := httpsSrv.Shutdown(ctx); shutdownErr != nil {
+				return shutdownErr
+			}
+			if internalSrv != nil {
+				if shutdownErr := internalSrv.Shutdown(ctx); shutdownErr != nil {
+					return shutdownErr
+				}
 			}
-			return httpsSrv.Shutdown(ctx)
+			// The error Socket Mode stopped with, if it did
+			return err
 		},
 	}
 	return cmd
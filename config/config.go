@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -8,22 +10,50 @@ type Config struct {
 	// the prometheus namespace
 	NS string
 
-	SlackBotAccessToken  string
-	SlackUserAccessToken string
-	SlackSigningSecret   string
-	SlackAdminGroupID    string
-	BroadcastChannelID   string
+	SlackBotAccessToken       string
+	SlackUserAccessToken      string
+	SlackSigningSecret        string
+	SlackAdminGroupID         string
+	BroadcastChannelID        string
+	SlackCacheTTL             time.Duration
+	SlackAdminRefreshInterval time.Duration
+	SlackSocketMode           bool
+	SlackAppToken             string
 
 	Addr    string
 	TLSAddr string
 	TLSCert string
 	TLSKey  string
+	// InternalAddr - where the endpoints that are not signed by Slack are served, not at all if empty
+	InternalAddr string
 
 	IncidentEnvs           string
 	IncidentRegions        string
 	IncidentSeverityLevels string
 	IncidentImpactLevels   string
 	IncidentDocTemplateURL string
+	PostmortemTemplatePath string
+	// how long the commander may go without posting a progress update, by severity
+	ReminderIntervals map[string]string
+	// how long an incident may go without progress before the commander is nudged and before it is escalated, by severity
+	StaleNudgeAfter    map[string]string
+	StaleEscalateAfter map[string]string
+	// how long somebody has to acknowledge a role by severity, and who is asked next in order
+	AcknowledgementWindows map[string]string
+	AcknowledgementBackups []string
+
+	ChannelNameTemplate   string
+	ChannelNameDateLayout string
+	ChannelNameTimezone   string
+
+	StorePath string
+
+	// the files of the on-call schedules by team, and the team that is on call for new incidents
+	OnCallSchedules     map[string]string
+	OnCallResponderTeam string
+
+	// the roles that may do each action, by action
+	Permissions map[string][]string
 }
 
 func FromViper(v *viper.Viper) (Config, error) {
@@ -36,17 +66,39 @@ func FromViper(v *viper.Viper) (Config, error) {
 	c.SlackSigningSecret = v.GetString("slack.signingSecret")
 	c.SlackAdminGroupID = v.GetString("slack.adminGroupID")
 	c.BroadcastChannelID = v.GetString("slack.broadcastChannelID")
+	c.SlackCacheTTL = v.GetDuration("slack.cacheTTL")
+	c.SlackAdminRefreshInterval = v.GetDuration("slack.adminRefreshInterval")
+	c.SlackSocketMode = v.GetBool("slack.socketMode")
+	c.SlackAppToken = v.GetString("slack.appToken")
 
 	c.Addr = v.GetString("addr")
 	c.TLSAddr = v.GetString("tls.addr")
 	c.TLSCert = v.GetString("tls.cert")
 	c.TLSKey = v.GetString("tls.key")
+	c.InternalAddr = v.GetString("internal.addr")
 
 	c.IncidentEnvs = v.GetString("incident.environments")
 	c.IncidentRegions = v.GetString("incident.regions")
 	c.IncidentSeverityLevels = v.GetString("incident.severityLevels")
 	c.IncidentImpactLevels = v.GetString("incident.impactLevels")
 	c.IncidentDocTemplateURL = v.GetString("incidentDocTemplateURL")
+	c.PostmortemTemplatePath = v.GetString("incident.postmortemTemplate")
+	c.ReminderIntervals = v.GetStringMapString("incident.reminderIntervals")
+	c.StaleNudgeAfter = v.GetStringMapString("incident.stale.nudgeAfter")
+	c.StaleEscalateAfter = v.GetStringMapString("incident.stale.escalateAfter")
+	c.AcknowledgementWindows = v.GetStringMapString("incident.acknowledgement.windows")
+	c.AcknowledgementBackups = v.GetStringSlice("incident.acknowledgement.backups")
+
+	c.ChannelNameTemplate = v.GetString("incident.channelName.template")
+	c.ChannelNameDateLayout = v.GetString("incident.channelName.dateLayout")
+	c.ChannelNameTimezone = v.GetString("incident.channelName.timezone")
+
+	c.StorePath = v.GetString("store.path")
+
+	c.OnCallSchedules = v.GetStringMapString("oncall.schedules")
+	c.OnCallResponderTeam = v.GetString("oncall.responderTeam")
+
+	c.Permissions = v.GetStringMapStringSlice("permissions")
 
 	return c, nil
 }
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AdminGroupMetrics - metrics about the refreshes of the admin user group, a nil *AdminGroupMetrics records nothing
+type AdminGroupMetrics struct {
+	members     prometheus.Gauge
+	lastRefresh prometheus.Gauge
+	failures    prometheus.Counter
+}
+
+// NewAdminGroupMetrics - create the admin user group metrics and register them with MetricsRegisterer
+func NewAdminGroupMetrics(namespace string) *AdminGroupMetrics {
+	m := &AdminGroupMetrics{
+		members: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "admin_group_members",
+			Help:      "The number of members of the admin user group at the last successful refresh, 0 while the group can not be fetched",
+		}),
+		lastRefresh: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "admin_group_last_refresh_timestamp_seconds",
+			Help:      "When the admin user group was last refreshed successfully, in seconds since the epoch",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "admin_group_refresh_failures_total",
+			Help:      "The number of times the admin user group could not be fetched",
+		}),
+	}
+	MetricsRegisterer.MustRegister(m.members, m.lastRefresh, m.failures)
+	return m
+}
+
+// AdminGroupRefreshed - record a successful refresh of the admin user group
+func (m *AdminGroupMetrics) AdminGroupRefreshed(members int, at time.Time) {
+	if m == nil {
+		return
+	}
+	m.members.Set(float64(members))
+	m.lastRefresh.Set(float64(at.UnixNano()) / float64(time.Second))
+}
+
+// AdminGroupRefreshFailed - record a failed refresh of the admin user group, which leaves it without members
+func (m *AdminGroupMetrics) AdminGroupRefreshFailed() {
+	if m == nil {
+		return
+	}
+	m.members.Set(0)
+	m.failures.Inc()
+}
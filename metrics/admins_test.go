@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminGroupMetrics(t *testing.T) {
+	MetricsRegisterer = prometheus.NewRegistry()
+	defer func() { MetricsRegisterer = prometheus.DefaultRegisterer }()
+
+	m := NewAdminGroupMetrics("test")
+	m.AdminGroupRefreshed(3, time.Unix(1656633600, 0))
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.members))
+	assert.Equal(t, 1656633600.0, testutil.ToFloat64(m.lastRefresh))
+
+	m.AdminGroupRefreshFailed()
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.members))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.failures))
+	assert.Equal(t, 1656633600.0, testutil.ToFloat64(m.lastRefresh))
+
+	// nothing is recorded without metrics
+	var none *AdminGroupMetrics
+	none.AdminGroupRefreshed(1, time.Now())
+	none.AdminGroupRefreshFailed()
+}
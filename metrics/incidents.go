@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IncidentMetrics - metrics about the lifecycle of incidents, a nil *IncidentMetrics records nothing
+type IncidentMetrics struct {
+	declared           *prometheus.CounterVec
+	resolved           *prometheus.CounterVec
+	open               prometheus.Gauge
+	timeToResolve      *prometheus.HistogramVec
+	timeBetweenUpdates prometheus.Histogram
+	stale              prometheus.Gauge
+	staleEscalations   *prometheus.CounterVec
+	timeToAcknowledge  *prometheus.HistogramVec
+}
+
+// incidentLabels - the labels of the incident counters
+var incidentLabels = []string{"severity", "impact", "environment", "security_related"}
+
+// Incidents last from minutes to days, updates are due every 15 minutes to a few hours,
+// and roles are acknowledged within seconds to an hour
+var (
+	timeToResolveBuckets      = []float64{5 * 60, 15 * 60, 30 * 60, 3600, 2 * 3600, 4 * 3600, 8 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600}
+	timeBetweenUpdatesBuckets = []float64{5 * 60, 15 * 60, 30 * 60, 3600, 2 * 3600, 4 * 3600, 8 * 3600}
+	timeToAcknowledgeBuckets  = []float64{30, 60, 2 * 60, 5 * 60, 10 * 60, 15 * 60, 30 * 60, 3600}
+)
+
+// NewIncidentMetrics - create the incident metrics and register them with MetricsRegisterer
+func NewIncidentMetrics(namespace string) *IncidentMetrics {
+	m := &IncidentMetrics{
+		declared: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "incidents_declared_total",
+				Help:      "The number of incidents declared",
+			},
+			incidentLabels,
+		),
+		resolved: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "incidents_resolved_total",
+				Help:      "The number of incidents resolved",
+			},
+			incidentLabels,
+		),
+		open: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "incidents_open",
+				Help:      "The number of incidents that are currently open",
+			},
+		),
+		timeToResolve: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "incident_time_to_resolve_seconds",
+				Help:      "The time from declaring an incident to resolving it",
+				Buckets:   timeToResolveBuckets,
+			},
+			[]string{"severity", "impact"},
+		),
+		timeBetweenUpdates: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "incident_time_between_updates_seconds",
+				Help:      "The time between progress updates of an incident, the first one counted from the declaration",
+				Buckets:   timeBetweenUpdatesBuckets,
+			},
+		),
+		stale: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "incidents_stale",
+				Help:      "The number of open incidents without a progress update for longer than their stale threshold",
+			},
+		),
+		staleEscalations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "incident_stale_escalations_total",
+				Help:      "The number of times an incident became stale and was escalated to the admin user group",
+			},
+			[]string{"severity"},
+		),
+		timeToAcknowledge: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "incident_time_to_acknowledge_seconds",
+				Help:      "The time from assigning a role of an incident to somebody acknowledging it",
+				Buckets:   timeToAcknowledgeBuckets,
+			},
+			[]string{"role", "severity"},
+		),
+	}
+	MetricsRegisterer.MustRegister(m.declared, m.resolved, m.open, m.timeToResolve, m.timeBetweenUpdates, m.stale, m.staleEscalations,
+		m.timeToAcknowledge)
+	return m
+}
+
+// IncidentDeclared - count a declared incident and add it to the open incidents
+func (m *IncidentMetrics) IncidentDeclared(severity, impact string, environments []string, securityRelated bool) {
+	if m == nil {
+		return
+	}
+	m.declared.WithLabelValues(severity, impact, environmentLabel(environments), strconv.FormatBool(securityRelated)).Inc()
+	m.open.Inc()
+}
+
+// IncidentResolved - count a resolved incident, remove it from the open incidents, and record how long it was open
+func (m *IncidentMetrics) IncidentResolved(severity, impact string, environments []string, securityRelated bool, timeToResolve time.Duration) {
+	if m == nil {
+		return
+	}
+	m.resolved.WithLabelValues(severity, impact, environmentLabel(environments), strconv.FormatBool(securityRelated)).Inc()
+	m.open.Dec()
+	if timeToResolve > 0 {
+		m.timeToResolve.WithLabelValues(severity, impact).Observe(timeToResolve.Seconds())
+	}
+}
+
+// IncidentReopened - add a reopened incident to the open incidents again
+func (m *IncidentMetrics) IncidentReopened() {
+	if m == nil {
+		return
+	}
+	m.open.Inc()
+}
+
+// SetOpenIncidents - set the number of open incidents, for example from the incident store on start
+func (m *IncidentMetrics) SetOpenIncidents(n int) {
+	if m == nil {
+		return
+	}
+	m.open.Set(float64(n))
+}
+
+// IncidentUpdated - record the time since the previous progress update of an incident
+func (m *IncidentMetrics) IncidentUpdated(sinceLastUpdate time.Duration) {
+	if m == nil {
+		return
+	}
+	m.timeBetweenUpdates.Observe(sinceLastUpdate.Seconds())
+}
+
+// SetStaleIncidents - set the number of open incidents that are stale
+func (m *IncidentMetrics) SetStaleIncidents(n int) {
+	if m == nil {
+		return
+	}
+	m.stale.Set(float64(n))
+}
+
+// IncidentStale - count an incident of the given severity that became stale
+func (m *IncidentMetrics) IncidentStale(severity string) {
+	if m == nil {
+		return
+	}
+	m.staleEscalations.WithLabelValues(severity).Inc()
+}
+
+// RoleAcknowledged - record how long it took to acknowledge a role of an incident of the given severity
+func (m *IncidentMetrics) RoleAcknowledged(role, severity string, timeToAcknowledge time.Duration) {
+	if m == nil {
+		return
+	}
+	m.timeToAcknowledge.WithLabelValues(role, severity).Observe(timeToAcknowledge.Seconds())
+}
+
+// environmentLabel - a single label value for the environments affected by an incident,
+// so that an incident affecting several environments is still counted once
+func environmentLabel(environments []string) string {
+	envs := append([]string(nil), environments...)
+	sort.Strings(envs)
+	return strings.Join(envs, ",")
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncidentMetrics(t *testing.T) {
+	MetricsRegisterer = prometheus.NewRegistry()
+	defer func() { MetricsRegisterer = prometheus.DefaultRegisterer }()
+
+	m := NewIncidentMetrics("test")
+	m.SetOpenIncidents(2)
+	m.IncidentDeclared("High", "Major", []string{"prod", "dev"}, true)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.declared.WithLabelValues("High", "Major", "dev,prod", "true")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.open))
+
+	m.IncidentResolved("High", "Major", []string{"dev", "prod"}, true, time.Hour)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.resolved.WithLabelValues("High", "Major", "dev,prod", "true")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.open))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.timeToResolve))
+
+	m.IncidentReopened()
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.open))
+
+	m.SetStaleIncidents(1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.stale))
+	m.IncidentStale("High")
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.staleEscalations.WithLabelValues("High")))
+
+	m.RoleAcknowledged("commander", "High", 2*time.Minute)
+	assert.Equal(t, 1, testutil.CollectAndCount(m.timeToAcknowledge))
+
+	// nothing is recorded without metrics
+	var none *IncidentMetrics
+	none.IncidentDeclared("High", "Major", nil, false)
+	none.IncidentResolved("High", "Major", nil, false, time.Hour)
+	none.IncidentReopened()
+	none.IncidentUpdated(time.Minute)
+	none.SetOpenIncidents(1)
+	none.SetStaleIncidents(1)
+	none.IncidentStale("High")
+	none.RoleAcknowledged("commander", "High", time.Minute)
+}
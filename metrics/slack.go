@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SlackAPIMetrics - metrics about the calls to the Slack API, a nil *SlackAPIMetrics records nothing
+type SlackAPIMetrics struct {
+	calls    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewSlackAPIMetrics - create the Slack API metrics and register them with MetricsRegisterer
+func NewSlackAPIMetrics(namespace string) *SlackAPIMetrics {
+	m := &SlackAPIMetrics{
+		calls: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "slack_api_calls_total",
+				Help:      "The number of calls to the Slack API by method and Slack error code, ok for successful calls",
+			},
+			[]string{"method", "error"},
+		),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "slack_api_call_duration_seconds",
+				Help:      "The latency of calls to the Slack API by method",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"method"},
+		),
+	}
+	MetricsRegisterer.MustRegister(m.calls, m.duration)
+	return m
+}
+
+// SlackAPICalled - record a call to the Slack API, errorCode is ok for a successful call
+func (m *SlackAPIMetrics) SlackAPICalled(method, errorCode string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.calls.WithLabelValues(method, errorCode).Inc()
+	m.duration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// SlackCacheMetrics - metrics about the cache of Slack lookups, a nil *SlackCacheMetrics records nothing
+type SlackCacheMetrics struct {
+	lookups *prometheus.CounterVec
+}
+
+// NewSlackCacheMetrics - create the Slack cache metrics and register them with MetricsRegisterer
+func NewSlackCacheMetrics(namespace string) *SlackCacheMetrics {
+	m := &SlackCacheMetrics{
+		lookups: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "slack_cache_lookups_total",
+				Help:      "The number of lookups in the cache of Slack API responses by cache and result, hit or miss",
+			},
+			[]string{"cache", "result"},
+		),
+	}
+	MetricsRegisterer.MustRegister(m.lookups)
+	return m
+}
+
+// CacheHit - record a lookup that was answered from the cache
+func (m *SlackCacheMetrics) CacheHit(cache string) {
+	if m == nil {
+		return
+	}
+	m.lookups.WithLabelValues(cache, "hit").Inc()
+}
+
+// CacheMiss - record a lookup that had to call the Slack API
+func (m *SlackCacheMetrics) CacheMiss(cache string) {
+	if m == nil {
+		return
+	}
+	m.lookups.WithLabelValues(cache, "miss").Inc()
+}
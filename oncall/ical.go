@@ -0,0 +1,201 @@
+package oncall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The layouts of iCalendar dates and times: https://datatracker.ietf.org/doc/html/rfc5545#section-3.3.5
+const (
+	icalDateLayout     = "20060102"
+	icalDateTimeLayout = "20060102T150405"
+)
+
+func loadICal(path string) ([]entry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseICal(string(b))
+}
+
+// icalProperty - a content line of an iCalendar file, like DTSTART;TZID=Europe/Stockholm:20220704T090000
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICalProperty - parse an unfolded content line
+func parseICalProperty(line string) (icalProperty, bool) {
+	nameAndParams, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return icalProperty{}, false
+	}
+	parts := strings.Split(nameAndParams, ";")
+	p := icalProperty{name: strings.ToUpper(parts[0]), params: map[string]string{}, value: value}
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return p, true
+}
+
+// parseICal - the shifts of the events of an iCalendar file, where the summary of an event is the Slack user ID
+// of the person on call. Events can repeat daily or weekly, but occurrences can not be excluded or moved.
+func parseICal(s string) ([]entry, error) {
+	// Long lines are folded by starting the next line with a space or a tab
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\n ", "")
+	s = strings.ReplaceAll(s, "\n\t", "")
+
+	entries := []entry{}
+	var event []icalProperty
+	inEvent := false
+	for _, line := range strings.Split(s, "\n") {
+		p, ok := parseICalProperty(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT"):
+			inEvent, event = true, nil
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT"):
+			e, err := icalEntry(event)
+			if err != nil {
+				return nil, fmt.Errorf("event %d: %w", len(entries)+1, err)
+			}
+			entries = append(entries, e)
+			inEvent = false
+		case inEvent:
+			event = append(event, p)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no events")
+	}
+	return entries, nil
+}
+
+// icalEntry - the shift of an event
+func icalEntry(props []icalProperty) (entry, error) {
+	var e entry
+	var rrule string
+	var err error
+	for _, p := range props {
+		switch p.name {
+		case "SUMMARY":
+			e.user = slackUser(p.value)
+		case "DTSTART":
+			if e.start, err = parseICalTime(p); err != nil {
+				return entry{}, err
+			}
+		case "DTEND":
+			if e.end, err = parseICalTime(p); err != nil {
+				return entry{}, err
+			}
+		case "RRULE":
+			rrule = p.value
+		case "EXDATE", "EXRULE", "RDATE", "RECURRENCE-ID":
+			// Ignoring them would put people on call for occurrences that were removed or moved
+			return entry{}, fmt.Errorf("unsupported property %s, write the changed shifts as events of their own", p.name)
+		}
+	}
+	if e.user == "" {
+		return entry{}, errors.New("no Slack user ID in the summary")
+	}
+	if e.start.IsZero() || !e.end.After(e.start) {
+		return entry{}, errors.New("a start and an end after the start are needed")
+	}
+	if rrule != "" {
+		if err := parseRRule(&e, rrule); err != nil {
+			return entry{}, err
+		}
+	}
+	return e, nil
+}
+
+// parseICalTime - parse a date, a time in UTC, a time in the time zone TZID, or a floating time taken as UTC
+func parseICalTime(p icalProperty) (time.Time, error) {
+	loc := time.UTC
+	if tzid, ok := p.params["TZID"]; ok {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone %q: %w", tzid, err)
+		}
+	}
+	if p.params["VALUE"] == "DATE" || len(p.value) == len(icalDateLayout) {
+		return time.ParseInLocation(icalDateLayout, p.value, loc)
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		return time.Parse(icalDateTimeLayout+"Z", p.value)
+	}
+	return time.ParseInLocation(icalDateTimeLayout, p.value, loc)
+}
+
+// The days of the week as written in BYDAY
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// parseRRule - make the shift repeat like a recurrence rule with the parts FREQ, INTERVAL, COUNT and UNTIL,
+// with a daily or weekly frequency. BYDAY is only accepted on a weekly rule with the weekday of the start,
+// which calendars add to weekly events, since it does not change when the shift repeats.
+func parseRRule(e *entry, rrule string) error {
+	interval := 1
+	var freq time.Duration
+	byDay := ""
+	for _, part := range strings.Split(rrule, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			switch strings.ToUpper(v) {
+			case "DAILY":
+				freq = dayLength
+			case "WEEKLY":
+				freq = 7 * dayLength
+			default:
+				return fmt.Errorf("unsupported recurrence frequency %q, only DAILY and WEEKLY are", v)
+			}
+		case "INTERVAL":
+			if interval, err = strconv.Atoi(v); err != nil || interval <= 0 {
+				return fmt.Errorf("invalid recurrence interval %q", v)
+			}
+		case "COUNT":
+			if e.count, err = strconv.Atoi(v); err != nil || e.count <= 0 {
+				return fmt.Errorf("invalid recurrence count %q", v)
+			}
+		case "UNTIL":
+			if e.until, err = parseICalTime(icalProperty{params: map[string]string{}, value: v}); err != nil {
+				return fmt.Errorf("invalid recurrence end %q: %w", v, err)
+			}
+		case "BYDAY":
+			byDay = strings.ToUpper(v)
+		case "WKST":
+		default:
+			return fmt.Errorf("unsupported recurrence rule part %q", k)
+		}
+	}
+	if freq == 0 {
+		return fmt.Errorf("no recurrence frequency in %q", rrule)
+	}
+	if byDay != "" {
+		if weekday, ok := icalWeekdays[byDay]; !ok || freq != 7*dayLength || weekday != e.start.Weekday() {
+			return fmt.Errorf("unsupported recurrence days %q, only the weekday of the start of a weekly event is", byDay)
+		}
+	}
+	e.every = time.Duration(interval) * freq
+	return nil
+}
@@ -0,0 +1,95 @@
+package oncall
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testICal = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:U1\r\n" +
+	"DTSTART:20220704T090000Z\r\n" +
+	"DTEND:20220705T090000Z\r\n" +
+	"RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:<@U2>\r\n" +
+	"DTSTART;TZID=Europe/Stockholm:20220705T110000\r\n" +
+	"DTEND;TZID=Europe/Stockholm:2022\r\n" +
+	" 0706T110000\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICal(t *testing.T) {
+	entries, err := parseICal(testICal)
+	require.NoError(t, err)
+	s := &Schedule{entries: entries}
+
+	shift, ok := s.At(day(4, 10))
+	assert.True(t, ok)
+	assert.Equal(t, Shift{User: "U1", Start: day(4, 9), End: day(5, 9)}, shift)
+
+	// the time zone of the event is used, and folded lines are unfolded
+	shift, ok = s.At(day(5, 10))
+	assert.True(t, ok)
+	assert.Equal(t, "U2", shift.User)
+	assert.True(t, shift.Start.Equal(day(5, 9)))
+	assert.True(t, shift.End.Equal(day(6, 9)))
+
+	// the event repeats every other week, three times
+	_, ok = s.At(day(11, 10))
+	assert.False(t, ok)
+	shift, ok = s.At(day(18, 10))
+	assert.True(t, ok)
+	assert.Equal(t, "U1", shift.User)
+	_, ok = s.At(time.Date(2022, 8, 15, 10, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+}
+
+func TestParseICalDaylightSavingTime(t *testing.T) {
+	// Winter time starts in Stockholm on 30 October 2022, and calendars add the weekday of weekly events
+	entries, err := parseICal("BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:U1\n" +
+		"DTSTART;TZID=Europe/Stockholm:20221024T090000\nDTEND;TZID=Europe/Stockholm:20221025T090000\n" +
+		"RRULE:FREQ=WEEKLY;BYDAY=MO;WKST=MO\nEND:VEVENT\nEND:VCALENDAR\n")
+	require.NoError(t, err)
+	s := &Schedule{entries: entries}
+
+	// the shift still starts at 09:00 in Stockholm, which is an hour later in UTC
+	_, ok := s.At(time.Date(2022, 10, 31, 7, 30, 0, 0, time.UTC))
+	assert.False(t, ok)
+	shift, ok := s.At(time.Date(2022, 10, 31, 8, 30, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.True(t, shift.Start.Equal(time.Date(2022, 10, 31, 8, 0, 0, 0, time.UTC)))
+	assert.True(t, shift.End.Equal(time.Date(2022, 11, 1, 8, 0, 0, 0, time.UTC)))
+}
+
+func TestParseICalErrors(t *testing.T) {
+	event := func(lines string) string {
+		return "BEGIN:VCALENDAR\nBEGIN:VEVENT\n" + lines + "END:VEVENT\nEND:VCALENDAR\n"
+	}
+	testdata := []string{
+		"BEGIN:VCALENDAR\nEND:VCALENDAR\n",
+		event("DTSTART:20220704T090000Z\nDTEND:20220705T090000Z\n"),
+		event("SUMMARY:U1\nDTSTART:20220704T090000Z\n"),
+		event("SUMMARY:U1\nDTSTART;TZID=Nowhere/Special:20220704T090000\nDTEND:20220705T090000Z\n"),
+		event("SUMMARY:U1\nDTSTART:20220704T090000Z\nDTEND:20220705T090000Z\nRRULE:FREQ=MONTHLY\n"),
+		event("SUMMARY:U1\nDTSTART:20220704T090000Z\nDTEND:20220705T090000Z\nRRULE:FREQ=WEEKLY;BYDAY=MO,TU\n"),
+		event("SUMMARY:U1\nDTSTART:20220704T090000Z\nDTEND:20220705T090000Z\nRRULE:FREQ=WEEKLY;BYDAY=TU\n"),
+		event("SUMMARY:U1\nDTSTART:20220704T090000Z\nDTEND:20220705T090000Z\nRRULE:FREQ=DAILY;BYDAY=MO\n"),
+		event("SUMMARY:U1\nDTSTART:20220704T090000Z\nDTEND:20220705T090000Z\nRRULE:FREQ=DAILY\nEXDATE:20220706T090000Z\n"),
+		event("SUMMARY:U1\nDTSTART:20220706T100000Z\nDTEND:20220707T090000Z\nRECURRENCE-ID:20220706T090000Z\n"),
+	}
+	for _, d := range testdata {
+		_, err := parseICal(d)
+		assert.Error(t, err, d)
+	}
+
+	// dates are whole days
+	entries, err := parseICal(event("SUMMARY:U1\nDTSTART;VALUE=DATE:20220704\nDTEND;VALUE=DATE:20220705\n"))
+	require.NoError(t, err)
+	assert.Equal(t, day(4, 0), entries[0].start)
+}
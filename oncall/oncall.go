@@ -0,0 +1,210 @@
+package oncall
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Shift - a period somebody is on call
+type Shift struct {
+	// User - the Slack user ID of the person on call
+	User  string
+	Start time.Time
+	End   time.Time
+}
+
+// slackUserID - the format of the Slack user IDs of the people on call
+var slackUserID = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+
+// dayLength - the length of a day when daylight saving time does not change
+const dayLength = 24 * time.Hour
+
+// entry - a shift of a user that is repeated every period, forever or count times or until a time
+type entry struct {
+	user string
+	// start, end - the first shift, a repeated shift starts and ends at the same time of day in their location
+	start, end time.Time
+	// every - how often the shift is repeated, 0 if it is not. Whole days are counted in the location of start,
+	// so that the shift keeps its time of day when daylight saving time changes.
+	every time.Duration
+	// count - how many times the shift happens, 0 for no limit
+	count int
+	// until - the last time the shift can start, zero for no limit
+	until time.Time
+	// override - whether the shift takes precedence over shifts that are not overrides
+	override bool
+}
+
+// repeat - t moved k periods ahead, whole days are counted in the location of t so that the time of day is kept
+func repeat(t time.Time, period time.Duration, k int) time.Time {
+	if period%dayLength == 0 {
+		return t.AddDate(0, 0, k*int(period/dayLength))
+	}
+	return t.Add(time.Duration(k) * period)
+}
+
+// occurrence - the k:th time the shift happens, false if it does not happen that many times
+func (e entry) occurrence(k int) (Shift, bool) {
+	if k < 0 || (e.every == 0 && k > 0) || (e.count > 0 && k >= e.count) {
+		return Shift{}, false
+	}
+	s := Shift{User: e.user, Start: repeat(e.start, e.every, k), End: repeat(e.end, e.every, k)}
+	if !e.until.IsZero() && s.Start.After(e.until) {
+		return Shift{}, false
+	}
+	return s, true
+}
+
+// latest - about which occurrence of the shift started last at t, it can be one off when days are not 24 hours long
+func (e entry) latest(t time.Time) int {
+	if e.every == 0 {
+		return 0
+	}
+	return int(t.Sub(e.start) / e.every)
+}
+
+// at - the occurrence of the shift that t is in
+func (e entry) at(t time.Time) (Shift, bool) {
+	if t.Before(e.start) {
+		return Shift{}, false
+	}
+	k := e.latest(t)
+	for _, k := range []int{k + 1, k, k - 1} {
+		if s, ok := e.occurrence(k); ok && !t.Before(s.Start) {
+			return s, t.Before(s.End)
+		}
+	}
+	return Shift{}, false
+}
+
+// nextStart - when the first occurrence of the shift that starts after t starts
+func (e entry) nextStart(t time.Time) (time.Time, bool) {
+	if t.Before(e.start) {
+		return e.start, true
+	}
+	k := e.latest(t)
+	for _, k := range []int{k, k + 1, k + 2} {
+		s, ok := e.occurrence(k)
+		if !ok {
+			break
+		}
+		if s.Start.After(t) {
+			return s.Start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Schedule - the on-call rotation of a team. Overrides take precedence over the other shifts, and of overlapping
+// shifts the one that started last does.
+type Schedule struct {
+	Team    string
+	entries []entry
+}
+
+// At - who is on call at t, their shift ends early if an override starts before it ends
+func (s *Schedule) At(t time.Time) (Shift, bool) {
+	var current Shift
+	var override, found bool
+	for _, e := range s.entries {
+		shift, ok := e.at(t)
+		if !ok || (override && !e.override) {
+			continue
+		}
+		if !found || (e.override && !override) || shift.Start.After(current.Start) {
+			current, override, found = shift, e.override, true
+		}
+	}
+	if !found || override {
+		return current, found
+	}
+	for _, e := range s.entries {
+		if start, ok := e.nextStart(t); ok && e.override && start.Before(current.End) {
+			current.End = start
+		}
+	}
+	return current, true
+}
+
+// Next - who is on call after the shift at t, or the first shift after t if nobody is on call at t
+func (s *Schedule) Next(t time.Time) (Shift, bool) {
+	from := t
+	if current, ok := s.At(t); ok {
+		from = current.End
+		if next, ok := s.At(from); ok {
+			return next, true
+		}
+	}
+	var start time.Time
+	found := false
+	for _, e := range s.entries {
+		if next, ok := e.nextStart(from); ok && (!found || next.Before(start)) {
+			start, found = next, true
+		}
+	}
+	if !found {
+		return Shift{}, false
+	}
+	return s.At(start)
+}
+
+// Schedules - the on-call schedules by team
+type Schedules map[string]*Schedule
+
+// Teams - the teams with a schedule, sorted
+func (s Schedules) Teams() []string {
+	teams := make([]string, 0, len(s))
+	for team := range s {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+	return teams
+}
+
+// Load - load the schedule of a team from an iCalendar file if its name ends with .ics, otherwise from a YAML file
+func Load(team, path string) (*Schedule, error) {
+	var entries []entry
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".ics") {
+		entries, err = loadICal(path)
+	} else {
+		entries, err = loadYAML(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load on-call schedule of %s: %w", team, err)
+	}
+	// A name or an email address instead of a user ID would only show up when somebody is paged
+	for _, e := range entries {
+		if !slackUserID.MatchString(e.user) {
+			return nil, fmt.Errorf("failed to load on-call schedule of %s: invalid Slack user ID %q", team, e.user)
+		}
+	}
+	return &Schedule{Team: team, entries: entries}, nil
+}
+
+// LoadSchedules - load the schedules of teams from their files, by team
+func LoadSchedules(paths map[string]string) (Schedules, error) {
+	schedules := Schedules{}
+	for team, path := range paths {
+		schedule, err := Load(team, path)
+		if err != nil {
+			return nil, err
+		}
+		schedules[team] = schedule
+	}
+	return schedules, nil
+}
+
+// slackUser - the Slack user ID in s, which can also be a mention like <@U0123ABCD>
+func slackUser(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "<@"), ">")
+	if i := strings.Index(s, "|"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
@@ -0,0 +1,139 @@
+package oncall
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testYAML = `
+rotations:
+  - start: 2022-07-04T09:00:00Z
+    shiftLength: 24h
+    users: [U1, "<@U2>", U3]
+overrides:
+  - user: U9
+    start: 2022-07-05T12:00:00Z
+    end: 2022-07-05T18:00:00Z
+`
+
+func day(d, hour int) time.Time {
+	return time.Date(2022, 7, d, hour, 0, 0, 0, time.UTC)
+}
+
+func TestScheduleAt(t *testing.T) {
+	entries, err := parseYAML([]byte(testYAML))
+	require.NoError(t, err)
+	s := &Schedule{Team: "platform", entries: entries}
+
+	testdata := []struct {
+		at    time.Time
+		shift Shift
+		ok    bool
+	}{
+		{day(4, 8), Shift{}, false},
+		{day(4, 9), Shift{User: "U1", Start: day(4, 9), End: day(5, 9)}, true},
+		// the shift ends when the override starts
+		{day(5, 10), Shift{User: "U2", Start: day(5, 9), End: day(5, 12)}, true},
+		{day(5, 12), Shift{User: "U9", Start: day(5, 12), End: day(5, 18)}, true},
+		{day(5, 18), Shift{User: "U2", Start: day(5, 9), End: day(6, 9)}, true},
+		{day(6, 9), Shift{User: "U3", Start: day(6, 9), End: day(7, 9)}, true},
+		// the rotation starts over
+		{day(7, 10), Shift{User: "U1", Start: day(7, 9), End: day(8, 9)}, true},
+	}
+	for _, d := range testdata {
+		shift, ok := s.At(d.at)
+		assert.Equal(t, d.ok, ok, d.at)
+		assert.Equal(t, d.shift, shift, d.at)
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	entries, err := parseYAML([]byte(testYAML))
+	require.NoError(t, err)
+	s := &Schedule{Team: "platform", entries: entries}
+
+	next, ok := s.Next(day(1, 0))
+	assert.True(t, ok)
+	assert.Equal(t, "U1", next.User)
+
+	next, ok = s.Next(day(4, 10))
+	assert.True(t, ok)
+	assert.Equal(t, Shift{User: "U2", Start: day(5, 9), End: day(5, 12)}, next)
+
+	next, ok = s.Next(day(5, 10))
+	assert.True(t, ok)
+	assert.Equal(t, "U9", next.User)
+
+	// nobody is next after the last override
+	s = &Schedule{entries: []entry{{user: "U1", start: day(4, 9), end: day(5, 9)}}}
+	_, ok = s.Next(day(4, 10))
+	assert.False(t, ok)
+}
+
+func TestScheduleDaylightSavingTime(t *testing.T) {
+	// Summer time starts in Stockholm on 27 March 2022
+	entries, err := parseYAML([]byte(`
+rotations:
+  - start: 2022-03-21T09:00:00+01:00
+    timezone: Europe/Stockholm
+    shiftLength: 168h
+    users: [U1, U2]
+`))
+	require.NoError(t, err)
+	s := &Schedule{entries: entries}
+
+	// the handover stays at 09:00 in Stockholm, which is an hour earlier in UTC
+	shift, ok := s.At(time.Date(2022, 3, 28, 7, 30, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, "U2", shift.User)
+	assert.True(t, shift.Start.Equal(time.Date(2022, 3, 28, 7, 0, 0, 0, time.UTC)))
+	next, ok := s.Next(time.Date(2022, 3, 28, 7, 30, 0, 0, time.UTC))
+	assert.True(t, ok)
+	assert.Equal(t, "U1", next.User)
+	assert.True(t, next.Start.Equal(time.Date(2022, 4, 4, 7, 0, 0, 0, time.UTC)))
+
+	_, err = parseYAML([]byte("rotations:\n  - start: 2022-03-21T09:00:00Z\n    timezone: Nowhere/Special\n    shiftLength: 24h\n    users: [U1]\n"))
+	assert.Error(t, err)
+}
+
+func TestParseYAML(t *testing.T) {
+	_, err := parseYAML([]byte("rotations:\n  - start: 2022-07-04T09:00:00Z\n    users: [U1]\n"))
+	assert.Error(t, err)
+	_, err = parseYAML([]byte("rotations:\n  - start: 2022-07-04T09:00:00Z\n    shiftLength: 24h\n"))
+	assert.Error(t, err)
+	_, err = parseYAML([]byte("overrides:\n  - user: U1\n    start: 2022-07-04T09:00:00Z\n    end: 2022-07-04T09:00:00Z\n"))
+	assert.Error(t, err)
+	_, err = parseYAML([]byte("{}"))
+	assert.Error(t, err)
+}
+
+func TestLoadSchedules(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "platform.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(testYAML), 0o600))
+	icsPath := filepath.Join(dir, "database.ics")
+	require.NoError(t, os.WriteFile(icsPath, []byte(testICal), 0o600))
+
+	schedules, err := LoadSchedules(map[string]string{"platform": yamlPath, "database": icsPath})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"database", "platform"}, schedules.Teams())
+	shift, ok := schedules["database"].At(day(4, 10))
+	assert.True(t, ok)
+	assert.Equal(t, "U1", shift.User)
+
+	_, err = LoadSchedules(map[string]string{"platform": filepath.Join(dir, "missing.yaml")})
+	assert.ErrorContains(t, err, "platform")
+
+	// users have to be Slack user IDs
+	for _, user := range []string{"alice", "alice@example.com", "u1", "C1"} {
+		path := filepath.Join(dir, "network.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("overrides:\n  - user: "+user+"\n    start: 2022-07-04T09:00:00Z\n    end: 2022-07-05T09:00:00Z\n"), 0o600))
+		_, err = LoadSchedules(map[string]string{"network": path})
+		assert.ErrorContains(t, err, "invalid Slack user ID", user)
+	}
+}
@@ -0,0 +1,88 @@
+package oncall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlSchedule - a schedule written in YAML, rotations hand the shift over from one user to the next
+// and overrides replace the rotations for a while. Shifts of whole days are handed over at the same time
+// of day in the time zone of the rotation, UTC or the offset of start if not given. For example:
+//
+//	rotations:
+//	  - start: 2022-07-04T09:00:00+02:00
+//	    timezone: Europe/Stockholm
+//	    shiftLength: 168h
+//	    users: [U0123ABCD, U0456EFGH]
+//	overrides:
+//	  - user: U0789IJKL
+//	    start: 2022-07-08T09:00:00+02:00
+//	    end: 2022-07-09T09:00:00+02:00
+type yamlSchedule struct {
+	Rotations []struct {
+		Start       time.Time     `yaml:"start"`
+		TimeZone    string        `yaml:"timezone"`
+		ShiftLength time.Duration `yaml:"shiftLength"`
+		Users       []string      `yaml:"users"`
+	} `yaml:"rotations"`
+	Overrides []struct {
+		User  string    `yaml:"user"`
+		Start time.Time `yaml:"start"`
+		End   time.Time `yaml:"end"`
+	} `yaml:"overrides"`
+}
+
+func loadYAML(path string) ([]entry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseYAML(b)
+}
+
+func parseYAML(b []byte) ([]entry, error) {
+	var s yamlSchedule
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	entries := []entry{}
+	for i, r := range s.Rotations {
+		if r.ShiftLength <= 0 {
+			return nil, fmt.Errorf("rotation %d: shiftLength must be positive", i+1)
+		}
+		if len(r.Users) == 0 {
+			return nil, fmt.Errorf("rotation %d: no users", i+1)
+		}
+		rotationStart := r.Start
+		if r.TimeZone != "" {
+			loc, err := time.LoadLocation(r.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("rotation %d: unknown time zone %q: %w", i+1, r.TimeZone, err)
+			}
+			rotationStart = rotationStart.In(loc)
+		}
+		for j, user := range r.Users {
+			start := repeat(rotationStart, r.ShiftLength, j)
+			entries = append(entries, entry{
+				user:  slackUser(user),
+				start: start,
+				end:   repeat(start, r.ShiftLength, 1),
+				every: time.Duration(len(r.Users)) * r.ShiftLength,
+			})
+		}
+	}
+	for i, o := range s.Overrides {
+		if o.User == "" || !o.End.After(o.Start) {
+			return nil, fmt.Errorf("override %d: a user and an end after the start are needed", i+1)
+		}
+		entries = append(entries, entry{user: slackUser(o.User), start: o.Start, end: o.End, override: true})
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no rotations or overrides")
+	}
+	return entries, nil
+}
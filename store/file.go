@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileStore - an IncidentStore that keeps all incidents in memory and
+// persists them to a single JSON file on every change
+type FileStore struct {
+	sync.RWMutex
+
+	path string
+	data fileData
+}
+
+type fileData struct {
+	LastNumber int                  `json:"lastNumber"`
+	Incidents  map[string]*Incident `json:"incidents"`
+}
+
+var _ IncidentStore = &FileStore{}
+
+// NewFileStore - open the file store at path, creating it on first write if it does not exist
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path: path,
+		data: fileData{Incidents: map[string]*Incident{}},
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read incident store %q: %w", path, err)
+	}
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		return nil, fmt.Errorf("failed to parse incident store %q: %w", path, err)
+	}
+	if s.data.Incidents == nil {
+		s.data.Incidents = map[string]*Incident{}
+	}
+	return s, nil
+}
+
+// Create - record a new incident
+func (s *FileStore) Create(ctx context.Context, inc *Incident) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.data.Incidents[inc.ChannelID]; ok {
+		return fmt.Errorf("channel %s: %w", inc.ChannelID, ErrExists)
+	}
+	c, err := clone(inc)
+	if err != nil {
+		return err
+	}
+	s.data.Incidents[inc.ChannelID] = c
+	if err := s.save(); err != nil {
+		delete(s.data.Incidents, inc.ChannelID)
+		return err
+	}
+	return nil
+}
+
+// Get - get the incident recorded for the given incident channel
+func (s *FileStore) Get(ctx context.Context, channelID string) (*Incident, error) {
+	s.RLock()
+	defer s.RUnlock()
+	inc, ok := s.data.Incidents[channelID]
+	if !ok {
+		return nil, fmt.Errorf("channel %s: %w", channelID, ErrNotFound)
+	}
+	return clone(inc)
+}
+
+// GetByNumber - get the incident with the given number
+func (s *FileStore) GetByNumber(ctx context.Context, number int) (*Incident, error) {
+	s.RLock()
+	defer s.RUnlock()
+	for _, inc := range s.data.Incidents {
+		if number > 0 && inc.Number == number {
+			return clone(inc)
+		}
+	}
+	return nil, fmt.Errorf("number %d: %w", number, ErrNotFound)
+}
+
+// NextNumber - reserve the next incident number
+func (s *FileStore) NextNumber(ctx context.Context) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.data.LastNumber++
+	if err := s.save(); err != nil {
+		s.data.LastNumber--
+		return 0, err
+	}
+	return s.data.LastNumber, nil
+}
+
+// LastNumber - the most recently reserved incident number
+func (s *FileStore) LastNumber(ctx context.Context) (int, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.data.LastNumber, nil
+}
+
+// List - list incidents ordered by declaration time
+func (s *FileStore) List(ctx context.Context, opts ListOptions) ([]*Incident, error) {
+	s.RLock()
+	defer s.RUnlock()
+	incidents := []*Incident{}
+	for _, inc := range s.data.Incidents {
+		if opts.Status != "" && inc.Status != opts.Status {
+			continue
+		}
+		c, err := clone(inc)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, c)
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].DeclaredAt.Before(incidents[j].DeclaredAt)
+	})
+	return incidents, nil
+}
+
+// Update - atomically modify the incident recorded for the given incident
+// channel, nothing is written if fn returns an error
+func (s *FileStore) Update(ctx context.Context, channelID string, fn func(inc *Incident) error) (*Incident, error) {
+	s.Lock()
+	defer s.Unlock()
+	orig, ok := s.data.Incidents[channelID]
+	if !ok {
+		return nil, fmt.Errorf("channel %s: %w", channelID, ErrNotFound)
+	}
+	inc, err := clone(orig)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(inc); err != nil {
+		return nil, err
+	}
+	// The channel is the key of the record, so it must not change
+	inc.ChannelID = channelID
+	s.data.Incidents[channelID] = inc
+	if err := s.save(); err != nil {
+		s.data.Incidents[channelID] = orig
+		return nil, err
+	}
+	return clone(inc)
+}
+
+// Resolve - mark the open incident recorded for the given incident channel as resolved
+func (s *FileStore) Resolve(ctx context.Context, channelID, resolver, resolution string, at time.Time) (*Incident, error) {
+	return s.Update(ctx, channelID, func(inc *Incident) error {
+		if inc.Status != StatusOpen {
+			return fmt.Errorf("channel %s: %w", channelID, ErrNotOpen)
+		}
+		inc.Status = StatusResolved
+		inc.Resolver = resolver
+		inc.Resolution = resolution
+		inc.ResolvedAt = at
+		inc.AddEvent(at, resolver, EventResolved, "Resolved the incident: "+resolution)
+		return nil
+	})
+}
+
+// Reopen - mark the resolved incident recorded for the given incident channel as open again,
+// the earlier resolution is kept in the timeline
+func (s *FileStore) Reopen(ctx context.Context, channelID, reopener, reason string, at time.Time) (*Incident, error) {
+	return s.Update(ctx, channelID, func(inc *Incident) error {
+		if inc.Status != StatusResolved {
+			return fmt.Errorf("channel %s: %w", channelID, ErrNotResolved)
+		}
+		inc.Status = StatusOpen
+		inc.Resolver = ""
+		inc.Resolution = ""
+		inc.ResolvedAt = time.Time{}
+		inc.AddEvent(at, reopener, EventReopened, "Reopened the incident: "+reason)
+		return nil
+	})
+}
+
+// save - write the whole store to a temporary file and move it in place,
+// so that a crash never leaves a partially written store behind
+func (s *FileStore) save() error {
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode incident store: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary incident store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write incident store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write incident store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace incident store %q: %w", s.path, err)
+	}
+	return nil
+}
+
+// clone - deep copy an incident so callers never share state with the store
+func clone(inc *Incident) (*Incident, error) {
+	b, err := json.Marshal(inc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy incident: %w", err)
+	}
+	c := &Incident{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("failed to copy incident: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "incidents.json")
+	s, err := NewFileStore(path)
+	require.NoError(t, err)
+
+	declared := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	err = s.Create(ctx, &Incident{
+		ChannelID:    "C1",
+		ChannelName:  "inc_first",
+		Status:       StatusOpen,
+		Commander:    "U1",
+		Environments: []string{"Production"},
+		DeclaredAt:   declared,
+	})
+	require.NoError(t, err)
+	err = s.Create(ctx, &Incident{ChannelID: "C2", Status: StatusOpen, DeclaredAt: declared.Add(time.Hour)})
+	require.NoError(t, err)
+
+	err = s.Create(ctx, &Incident{ChannelID: "C1"})
+	assert.ErrorIs(t, err, ErrExists)
+
+	_, err = s.Get(ctx, "doesnotexist")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// modifying a returned incident must not modify the store
+	inc, err := s.Get(ctx, "C1")
+	require.NoError(t, err)
+	inc.Environments[0] = "Staging"
+	inc, err = s.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Production"}, inc.Environments)
+
+	inc, err = s.Update(ctx, "C1", func(inc *Incident) error {
+		inc.Commander = "U2"
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "U2", inc.Commander)
+
+	_, err = s.Update(ctx, "C1", func(inc *Incident) error {
+		inc.Commander = "U3"
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	inc, err = s.Resolve(ctx, "C2", "U1", "Restarted the service", declared.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, StatusResolved, inc.Status)
+	require.Len(t, inc.Timeline, 1)
+	assert.Equal(t, EventResolved, inc.Timeline[0].Kind)
+	assert.Equal(t, "U1", inc.Timeline[0].Actor)
+
+	// resolving again must not overwrite the resolution
+	_, err = s.Resolve(ctx, "C2", "U2", "Restarted it again", declared.Add(3*time.Hour))
+	assert.ErrorIs(t, err, ErrNotOpen)
+	inc, err = s.Get(ctx, "C2")
+	require.NoError(t, err)
+	assert.Equal(t, "U1", inc.Resolver)
+	assert.Equal(t, "Restarted the service", inc.Resolution)
+	assert.Len(t, inc.Timeline, 1)
+
+	open, err := s.List(ctx, ListOptions{Status: StatusOpen})
+	require.NoError(t, err)
+	require.Len(t, open, 1)
+	assert.Equal(t, "C1", open[0].ChannelID)
+
+	all, err := s.List(ctx, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "C1", all[0].ChannelID)
+	assert.Equal(t, "C2", all[1].ChannelID)
+
+	n, err := s.NextNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = s.Update(ctx, "C2", func(inc *Incident) error {
+		inc.Number = n
+		return nil
+	})
+	require.NoError(t, err)
+	n, err = s.NextNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	inc, err = s.GetByNumber(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "C2", inc.ChannelID)
+	_, err = s.GetByNumber(ctx, 2)
+	assert.ErrorIs(t, err, ErrNotFound)
+	// incidents recorded before numbering must not be found by number
+	_, err = s.GetByNumber(ctx, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// reopening the store must give back the same incidents
+	s, err = NewFileStore(path)
+	require.NoError(t, err)
+	n, err = s.LastNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	inc, err = s.Get(ctx, "C1")
+	require.NoError(t, err)
+	assert.Equal(t, "U2", inc.Commander)
+	assert.True(t, declared.Equal(inc.DeclaredAt))
+	inc, err = s.Get(ctx, "C2")
+	require.NoError(t, err)
+	assert.Equal(t, "Restarted the service", inc.Resolution)
+
+	inc, err = s.Reopen(ctx, "C2", "U2", "It is down again", declared.Add(3*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, StatusOpen, inc.Status)
+	assert.Empty(t, inc.Resolution)
+	assert.True(t, inc.ResolvedAt.IsZero())
+	require.Len(t, inc.Timeline, 2)
+	assert.Equal(t, EventReopened, inc.Timeline[1].Kind)
+
+	_, err = s.Reopen(ctx, "C2", "U2", "Still down", declared.Add(4*time.Hour))
+	assert.ErrorIs(t, err, ErrNotResolved)
+}
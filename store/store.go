@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound - returned when no incident is recorded for a channel
+	ErrNotFound = errors.New("incident not found")
+	// ErrExists - returned when an incident is already recorded for a channel
+	ErrExists = errors.New("incident already exists")
+	// ErrNotResolved - returned when reopening an incident that is still open
+	ErrNotResolved = errors.New("incident not resolved")
+	// ErrNotOpen - returned when resolving an incident that is already resolved
+	ErrNotOpen = errors.New("incident not open")
+)
+
+// Status - the lifecycle state of an incident
+type Status string
+
+const (
+	StatusOpen     Status = "open"
+	StatusResolved Status = "resolved"
+)
+
+// Incident - everything the bot knows about an incident
+type Incident struct {
+	// ChannelID - the ID of the incident channel, used as the key of the record
+	ChannelID string `json:"channelID"`
+	// Number - the sequential number of the incident, 0 for incidents recorded before numbering
+	Number int `json:"number,omitempty"`
+	// ChannelName - the name of the incident channel
+	ChannelName string `json:"channelName"`
+	// BroadcastChannelID - the channel the incident was announced in
+	BroadcastChannelID string `json:"broadcastChannelID"`
+	// BroadcastTS - the timestamp of the announcement in the broadcast channel
+	BroadcastTS string `json:"broadcastTS,omitempty"`
+	// Status - whether the incident is open or resolved
+	Status Status `json:"status"`
+	// SecurityRelated - whether the incident channel is private
+	SecurityRelated bool `json:"securityRelated"`
+
+	Declarer  string `json:"declarer"`
+	Responder string `json:"responder"`
+	Commander string `json:"commander"`
+
+	Severity     string   `json:"severity"`
+	Impact       string   `json:"impact"`
+	Environments []string `json:"environments"`
+	Regions      []string `json:"regions"`
+	Summary      string   `json:"summary"`
+
+	// Subscribers - the people that get a direct message about updates
+	Subscribers []string `json:"subscribers,omitempty"`
+	// AffectedUsers - the people that reported being affected by the incident
+	AffectedUsers []string `json:"affectedUsers,omitempty"`
+
+	// ReminderID - the channel reminder about updating progress added by versions before 0.37.0,
+	// deleted when the incident is resolved
+	ReminderID string `json:"reminderID,omitempty"`
+	// LastReminderAt - when the commander was last reminded to post a progress update
+	LastReminderAt time.Time `json:"lastReminderAt"`
+	// StaleNudgedAt - when the commander was last told that the incident is becoming stale
+	StaleNudgedAt time.Time `json:"staleNudgedAt"`
+	// StaleAt - when the incident was last escalated for being stale, it is stale until the next progress
+	StaleAt time.Time `json:"staleAt"`
+
+	// Updates - progress updates posted during the incident, oldest first
+	Updates []ProgressUpdate `json:"updates,omitempty"`
+	// SeverityChanges - changes of severity and impact after declaration, oldest first
+	SeverityChanges []SeverityChange `json:"severityChanges,omitempty"`
+	// RoleChanges - handovers of the commander and responder roles, oldest first
+	RoleChanges []RoleChange `json:"roleChanges,omitempty"`
+	// Acknowledgements - the latest request to acknowledge each role
+	Acknowledgements []Acknowledgement `json:"acknowledgements,omitempty"`
+	// Timeline - everything that happened during the incident, oldest first
+	Timeline []Event `json:"timeline,omitempty"`
+
+	DeclaredAt time.Time `json:"declaredAt"`
+	ResolvedAt time.Time `json:"resolvedAt,omitempty"`
+	Resolver   string    `json:"resolver,omitempty"`
+	Resolution string    `json:"resolution,omitempty"`
+	// ResolutionChannelID and ResolutionTS - the message announcing the latest resolution
+	ResolutionChannelID string `json:"resolutionChannelID,omitempty"`
+	ResolutionTS        string `json:"resolutionTS,omitempty"`
+}
+
+// ProgressUpdate - an update about how the work on an incident is progressing
+type ProgressUpdate struct {
+	At     time.Time `json:"at"`
+	Author string    `json:"author"`
+	// Status - the stage the incident is in, for example "Investigating"
+	Status string `json:"status"`
+	// Changes - what changed since the previous update
+	Changes string `json:"changes"`
+	// NextUpdateAt - when the next update is due
+	NextUpdateAt time.Time `json:"nextUpdateAt"`
+}
+
+// SeverityChange - an escalation or de-escalation of an incident
+type SeverityChange struct {
+	At           time.Time `json:"at"`
+	By           string    `json:"by"`
+	FromSeverity string    `json:"fromSeverity"`
+	ToSeverity   string    `json:"toSeverity"`
+	FromImpact   string    `json:"fromImpact"`
+	ToImpact     string    `json:"toImpact"`
+	Reason       string    `json:"reason"`
+}
+
+// RoleChange - a handover of a role from one person to another
+type RoleChange struct {
+	At time.Time `json:"at"`
+	By string    `json:"by"`
+	// Role - the role that was handed over, "commander" or "responder"
+	Role string `json:"role"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	Note string `json:"note,omitempty"`
+}
+
+// Acknowledgement - a request to the holder of a role to acknowledge that they are on the incident
+type Acknowledgement struct {
+	// Role - the role to acknowledge, "commander" or "responder"
+	Role       string    `json:"role"`
+	Assignee   string    `json:"assignee"`
+	AssignedAt time.Time `json:"assignedAt"`
+	// Asked - the people asked to acknowledge, the assignee first and then backups in the order they were asked
+	Asked []string `json:"asked"`
+	// AskedAt - when the last of Asked was asked
+	AskedAt time.Time `json:"askedAt"`
+	// Exhausted - whether everybody that could be asked was asked without anybody acknowledging
+	Exhausted bool      `json:"exhausted,omitempty"`
+	AckedAt   time.Time `json:"ackedAt,omitempty"`
+	AckedBy   string    `json:"ackedBy,omitempty"`
+}
+
+// Acknowledgement - the latest request to acknowledge the role, nil if there is none
+func (inc *Incident) Acknowledgement(role string) *Acknowledgement {
+	for i := range inc.Acknowledgements {
+		if inc.Acknowledgements[i].Role == role {
+			return &inc.Acknowledgements[i]
+		}
+	}
+	return nil
+}
+
+// SetAcknowledgement - replace the request to acknowledge the role of ack
+func (inc *Incident) SetAcknowledgement(ack Acknowledgement) {
+	if existing := inc.Acknowledgement(ack.Role); existing != nil {
+		*existing = ack
+		return
+	}
+	inc.Acknowledgements = append(inc.Acknowledgements, ack)
+}
+
+// EventKind - what kind of thing happened during an incident
+type EventKind string
+
+const (
+	EventDeclared        EventKind = "declared"
+	EventRoleChanged     EventKind = "role_changed"
+	EventUpdated         EventKind = "updated"
+	EventSeverityChanged EventKind = "severity_changed"
+	EventResolved        EventKind = "resolved"
+	EventArchived        EventKind = "archived"
+	EventReopened        EventKind = "reopened"
+	EventAcknowledged    EventKind = "acknowledged"
+)
+
+// Event - an entry in the timeline of an incident
+type Event struct {
+	At    time.Time `json:"at"`
+	Actor string    `json:"actor"`
+	Kind  EventKind `json:"kind"`
+	// Text - a human readable description of the event, may contain Slack user and channel references
+	Text string `json:"text"`
+}
+
+// AddEvent - add an event to the end of the timeline of the incident
+func (inc *Incident) AddEvent(at time.Time, actor string, kind EventKind, text string) {
+	inc.Timeline = append(inc.Timeline, Event{
+		At:    at,
+		Actor: actor,
+		Kind:  kind,
+		Text:  text,
+	})
+}
+
+// ListOptions - narrow down the incidents returned by List
+type ListOptions struct {
+	// Status - only return incidents in this state, all incidents when empty
+	Status Status
+}
+
+// IncidentStore - persistence of incident records
+type IncidentStore interface {
+	// Create - record a new incident, fails with ErrExists if the channel is already recorded
+	Create(ctx context.Context, inc *Incident) error
+	// Get - get the incident recorded for the given incident channel
+	Get(ctx context.Context, channelID string) (*Incident, error)
+	// GetByNumber - get the incident with the given number
+	GetByNumber(ctx context.Context, number int) (*Incident, error)
+	// NextNumber - reserve the next incident number, numbers are never handed out twice
+	NextNumber(ctx context.Context) (int, error)
+	// LastNumber - the most recently reserved incident number, 0 if none
+	LastNumber(ctx context.Context) (int, error)
+	// List - list incidents ordered by declaration time
+	List(ctx context.Context, opts ListOptions) ([]*Incident, error)
+	// Update - atomically modify the incident recorded for the given incident channel
+	Update(ctx context.Context, channelID string, fn func(inc *Incident) error) (*Incident, error)
+	// Resolve - mark the open incident recorded for the given incident channel as resolved
+	Resolve(ctx context.Context, channelID, resolver, resolution string, at time.Time) (*Incident, error)
+	// Reopen - mark the resolved incident recorded for the given incident channel as open again
+	Reopen(ctx context.Context, channelID, reopener, reason string, at time.Time) (*Incident, error)
+}